@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mongodbatlas_exporter/collector"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// runBackfill writes the measurements of the time range given by the backfill flags.
+func runBackfill(logger log.Logger) error {
+	start, err := time.Parse(time.RFC3339, *backfillStart)
+	if err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	end := time.Now()
+	if *backfillEnd != "" {
+		end, err = time.Parse(time.RFC3339, *backfillEnd)
+		if err != nil {
+			return fmt.Errorf("invalid end: %w", err)
+		}
+	}
+
+	client, err := newClient(logger)
+	if err != nil {
+		return err
+	}
+	client.ApplyRateLimits()
+
+	var w io.Writer = os.Stdout
+	if *backfillOutput != "-" {
+		f, err := os.Create(*backfillOutput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return collector.Backfill(context.Background(), logger, client, w, collector.BackfillOptions{
+		Start:  start,
+		End:    end,
+		Window: *backfillWindow,
+	})
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"context"
+	"mongodbatlas_exporter/measurer"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	alertsPrefix = "alerts"
+
+	alertOpenHelp  = "Number of unresolved Atlas alerts with the given labels."
+	alertCountHelp = "Number of unresolved Atlas alerts by status."
+)
+
+// Alert collects the unresolved alerts of a project.
+type Alert struct {
+	scrapeMetrics
+	client    a.Client
+	logger    log.Logger
+	projectID string
+	timeout   time.Duration
+
+	open, count *prometheus.Desc
+}
+
+// NewAlertCollector creates the alert collector of a project.
+// timeout is the deadline of its requests when it is not collected with the context of a scrape.
+func NewAlertCollector(logger log.Logger, client a.Client, projectID string, timeout time.Duration) (*Alert, error) {
+	constLabels := prometheus.Labels{"project_id": projectID}
+
+	return &Alert{
+		scrapeMetrics: newScrapeMetrics(alertsPrefix, constLabels),
+		client:        client,
+		logger:        logger,
+		projectID:     projectID,
+		timeout:       timeout,
+		//mongodbatlas_alert_open is singular like the ALERTS metric of Prometheus, one series per kind of alert.
+		open: prometheus.NewDesc(prometheus.BuildFQName(namespace, "alert", "open"),
+			alertOpenHelp, (&measurer.Alert{}).PromVariableLabelNames(), constLabels),
+		count: prometheus.NewDesc(prometheus.BuildFQName(namespace, alertsPrefix, "count"),
+			alertCountHelp, []string{"status"}, constLabels),
+	}, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *Alert) Describe(ch chan<- *prometheus.Desc) {
+	c.scrapeMetrics.describe(ch)
+	ch <- c.open
+	ch <- c.count
+}
+
+// Collect implements prometheus.Collector.
+func (c *Alert) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	c.CollectContext(ctx, ch)
+}
+
+// CollectContext implements ContextCollector.
+func (c *Alert) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	c.totalScrapes.Inc()
+	defer c.scrapeMetrics.collect(ch)
+
+	//alerts of one config can share all labels, e.g. a project wide event,
+	//so identical label sets are counted instead of reported twice.
+	open := make(map[string]float64)
+	openLabels := make(map[string][]string)
+	counts := make([]float64, len(measurer.AlertStatuses))
+	for i, status := range measurer.AlertStatuses {
+		alerts, err := c.client.ListAlerts(ctx, c.projectID, status)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "scrape failure", "project", c.projectID, "err", err)
+			c.scrapeFailures.Inc()
+			c.up.Set(0)
+			return
+		}
+
+		for j := range alerts {
+			labels := measurer.AlertFromMongodbAtlasAlert(&alerts[j]).PromVariableLabelValues()
+			key := strings.Join(labels, "\xff")
+			open[key]++
+			openLabels[key] = labels
+		}
+		counts[i] = float64(len(alerts))
+	}
+	c.up.Set(1)
+
+	for i, status := range measurer.AlertStatuses {
+		ch <- prometheus.MustNewConstMetric(c.count, prometheus.GaugeValue, counts[i], status)
+	}
+	for key, value := range open {
+		ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, value, openLabels[key]...)
+	}
+}
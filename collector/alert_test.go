@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func (c *MockClient) ListAlerts(_ context.Context, _ string, status string) ([]mongodbatlas.Alert, *a.HTTPError) {
+	var alerts []mongodbatlas.Alert
+	for _, alert := range c.givenAlerts {
+		if alert.Status == status {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+//TestAlertCollector checks that alerts with identical labels are counted
+//and that every unresolved status is reported.
+func TestAlertCollector(t *testing.T) {
+	hostAlert := mongodbatlas.Alert{
+		AlertConfigID:   "config-1",
+		EventTypeName:   "OUTSIDE_METRIC_THRESHOLD",
+		Status:          "OPEN",
+		ClusterName:     "cluster0",
+		ReplicaSetName:  "cluster0-shard-0",
+		HostnameAndPort: "cluster0-shard-00-00:27017",
+		MetricName:      "NORMALIZED_SYSTEM_CPU_USER",
+	}
+	projectAlert := mongodbatlas.Alert{
+		AlertConfigID: "config-2",
+		EventTypeName: "USERS_WITHOUT_MULTI_FACTOR_AUTH",
+		Status:        "OPEN",
+	}
+	mock := &MockClient{
+		givenAlerts: []mongodbatlas.Alert{hostAlert, projectAlert, projectAlert},
+	}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	alertCollector, err := NewAlertCollector(logger, mock, "testProjectID", DefaultFetchTimeout)
+	assert.NoError(t, err)
+
+	expected := `
+# HELP mongodbatlas_alerts_count Number of unresolved Atlas alerts by status.
+# TYPE mongodbatlas_alerts_count gauge
+mongodbatlas_alerts_count{project_id="testProjectID",status="OPEN"} 3
+mongodbatlas_alerts_count{project_id="testProjectID",status="TRACKING"} 0
+# HELP mongodbatlas_alert_open Number of unresolved Atlas alerts with the given labels.
+# TYPE mongodbatlas_alert_open gauge
+mongodbatlas_alert_open{alert_config_id="config-1",cluster="cluster0",event_type="OUTSIDE_METRIC_THRESHOLD",host="cluster0-shard-00-00:27017",metric_name="NORMALIZED_SYSTEM_CPU_USER",project_id="testProjectID",rs_name="cluster0-shard-0",status="OPEN"} 1
+mongodbatlas_alert_open{alert_config_id="config-2",cluster="",event_type="USERS_WITHOUT_MULTI_FACTOR_AUTH",host="",metric_name="",project_id="testProjectID",rs_name="",status="OPEN"} 2
+# HELP mongodbatlas_alerts_up Was the last communication with MongoDB Atlas API successful.
+# TYPE mongodbatlas_alerts_up gauge
+mongodbatlas_alerts_up{project_id="testProjectID"} 1
+`
+
+	err = testutil.CollectAndCompare(alertCollector, strings.NewReader(expected),
+		"mongodbatlas_alerts_count", "mongodbatlas_alert_open", "mongodbatlas_alerts_up")
+	assert.NoError(t, err)
+}
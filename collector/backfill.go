@@ -0,0 +1,233 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mongodbatlas_exporter/collector/transformer"
+	"mongodbatlas_exporter/measurer"
+	m "mongodbatlas_exporter/model"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// BackfillClient is the part of the Atlas client a backfill needs.
+type BackfillClient interface {
+	ListProjects(ctx context.Context) ([]string, *a.HTTPError)
+	ListProcesses(ctx context.Context, projectID string) ([]*mongodbatlas.Process, *a.HTTPError)
+	ListDisks(ctx context.Context, p *mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *a.HTTPError)
+	GetProcessMeasurementsRange(ctx context.Context, p *measurer.Process, start, end time.Time) ([]*mongodbatlas.Measurements, *a.HTTPError)
+	GetDiskMeasurementsRange(ctx context.Context, p *measurer.Process, d *measurer.Disk, start, end time.Time) ([]*mongodbatlas.Measurements, *a.HTTPError)
+	measurer.Rules
+}
+
+// BackfillOptions configure the time range of a backfill.
+type BackfillOptions struct {
+	Start, End time.Time
+	//Window is the time range of a single request, long ranges are split into several requests.
+	Window time.Duration
+}
+
+// Backfill writes the process and disk measurements of every project between Start and End
+// as OpenMetrics text, e.g. for promtool tsdb create-blocks-from openmetrics.
+// Names, units and values are transformed exactly like the ones of the process collectors.
+func Backfill(ctx context.Context, logger log.Logger, client BackfillClient, w io.Writer, options BackfillOptions) error {
+	if !options.Start.Before(options.End) {
+		return fmt.Errorf("start %s is not before end %s", options.Start, options.End)
+	}
+	if options.Window <= 0 {
+		options.Window = options.End.Sub(options.Start)
+	}
+
+	projectIDs, httpErr := client.ListProjects(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	families := make(map[string]*backfillFamily)
+	for _, projectID := range projectIDs {
+		processes, httpErr := client.ListProcesses(ctx, projectID)
+		if httpErr != nil {
+			return httpErr
+		}
+
+		for _, p := range processes {
+			process := measurer.ProcessFromMongodbAtlasProcess(p)
+			err := backfillWindows(options, func(start, end time.Time) error {
+				measurements, httpErr := client.GetProcessMeasurementsRange(ctx, process, start, end)
+				if httpErr != nil {
+					return httpErr
+				}
+				addBackfillSamples(logger, client, families, process, processesPrefix, measurements)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			//MONGOS nodes have disks that do not report data, like in the process collector.
+			if p.TypeName == a.TYPE_MONGOS {
+				continue
+			}
+			disks, httpErr := client.ListDisks(ctx, p)
+			if httpErr != nil {
+				return httpErr
+			}
+			for _, d := range disks {
+				disk := measurer.DiskFromMongodbAtlasProcessDisk(p, d)
+				err := backfillWindows(options, func(start, end time.Time) error {
+					measurements, httpErr := client.GetDiskMeasurementsRange(ctx, process, disk, start, end)
+					if httpErr != nil {
+						return httpErr
+					}
+					addBackfillSamples(logger, client, families, disk, disksPrefix, measurements)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			level.Info(logger).Log("msg", "backfilled process", "process", p.ID, "project", projectID)
+		}
+	}
+
+	return writeOpenMetrics(w, families)
+}
+
+//backfillWindows calls fetch for consecutive windows covering the time range of the backfill.
+func backfillWindows(options BackfillOptions, fetch func(start, end time.Time) error) error {
+	for start := options.Start; start.Before(options.End); start = start.Add(options.Window) {
+		end := start.Add(options.Window)
+		if end.After(options.End) {
+			end = options.End
+		}
+		if err := fetch(start, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//backfillFamily holds the samples of one metric name by label set.
+type backfillFamily struct {
+	help      string
+	valueType prometheus.ValueType
+	//series maps the formatted labels to the values by unix timestamp.
+	series map[string]map[int64]float64
+}
+
+func addBackfillSamples(logger log.Logger, rules measurer.Rules, families map[string]*backfillFamily, source measurer.Measurer, collectorPrefix string, measurements []*mongodbatlas.Measurements) {
+	for _, measurement := range measurements {
+		metadata := &m.MeasurementMetadata{Name: measurement.Name, Units: m.UnitEnum(measurement.Units)}
+		metric, err := measurer.NewPromMetric(source, metadata, namespace, collectorPrefix, rules)
+		if err != nil {
+			level.Debug(logger).Log("msg", "skipping measurement", "measurement", measurement.Name, "err", err)
+			continue
+		}
+		if metric == nil {
+			continue
+		}
+		samples, err := transformer.TransformDataPoints(&m.Measurement{DataPoints: measurement.DataPoints, Units: metadata.Units})
+		if err != nil || len(samples) == 0 {
+			//measurements without datapoints are common, e.g. FTS_* without full text search.
+			continue
+		}
+
+		family, ok := families[metric.FQName]
+		if !ok {
+			family = &backfillFamily{
+				help:      metric.Help,
+				valueType: metric.Type,
+				series:    make(map[string]map[int64]float64),
+			}
+			families[metric.FQName] = family
+		}
+		labels := formatOpenMetricsLabels(metric.ConstLabels)
+		series, ok := family.series[labels]
+		if !ok {
+			series = make(map[int64]float64)
+			family.series[labels] = series
+		}
+		//overlapping windows return the datapoints at their bounds twice.
+		for _, sample := range samples {
+			series[sample.Timestamp.Unix()] = metric.Scale(sample.Value)
+		}
+	}
+}
+
+//writeOpenMetrics writes the families sorted by name, each series in time order, as promtool expects them.
+func writeOpenMetrics(w io.Writer, families map[string]*backfillFamily) error {
+	bw := bufio.NewWriter(w)
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		family := families[name]
+		typeName := "gauge"
+		familyName := name
+		switch {
+		//OpenMetrics counter samples need the _total suffix, mapped counters without it are written as unknown.
+		case family.valueType == prometheus.CounterValue && strings.HasSuffix(name, "_total"):
+			typeName = "counter"
+			familyName = strings.TrimSuffix(name, "_total")
+		case family.valueType != prometheus.GaugeValue:
+			typeName = "unknown"
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", familyName, escapeOpenMetricsHelp(family.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", familyName, typeName)
+
+		labelSets := make([]string, 0, len(family.series))
+		for labels := range family.series {
+			labelSets = append(labelSets, labels)
+		}
+		sort.Strings(labelSets)
+
+		for _, labels := range labelSets {
+			series := family.series[labels]
+			timestamps := make([]int64, 0, len(series))
+			for timestamp := range series {
+				timestamps = append(timestamps, timestamp)
+			}
+			sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+			for _, timestamp := range timestamps {
+				fmt.Fprintf(bw, "%s%s %s %d\n", name, labels, strconv.FormatFloat(series[timestamp], 'g', -1, 64), timestamp)
+			}
+		}
+	}
+	fmt.Fprint(bw, "# EOF\n")
+	return bw.Flush()
+}
+
+func formatOpenMetricsLabels(labels prometheus.Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + openMetricsLabelEscaper.Replace(labels[name]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var openMetricsLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeOpenMetricsHelp(help string) string {
+	return openMetricsLabelEscaper.Replace(help)
+}
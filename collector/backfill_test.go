@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"mongodbatlas_exporter/config"
+	"mongodbatlas_exporter/measurer"
+	"mongodbatlas_exporter/model"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+type backfillMockClient struct {
+	//ranges records the time ranges of the process measurement requests.
+	ranges [][2]time.Time
+}
+
+func (c *backfillMockClient) ListProjects(context.Context) ([]string, *a.HTTPError) {
+	return []string{"testProjectID"}, nil
+}
+
+func (c *backfillMockClient) ListProcesses(context.Context, string) ([]*mongodbatlas.Process, *a.HTTPError) {
+	return []*mongodbatlas.Process{&testAtlasProcess}, nil
+}
+
+func (c *backfillMockClient) ListDisks(context.Context, *mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *a.HTTPError) {
+	return []*mongodbatlas.ProcessDisk{{PartitionName: "data"}}, nil
+}
+
+func (c *backfillMockClient) GetProcessMeasurementsRange(_ context.Context, _ *measurer.Process, start, end time.Time) ([]*mongodbatlas.Measurements, *a.HTTPError) {
+	c.ranges = append(c.ranges, [2]time.Time{start, end})
+	value := float32(3)
+	return []*mongodbatlas.Measurements{
+		{
+			Name:  "QUERY_EXECUTOR_SCANNED",
+			Units: "SCALAR_PER_SECOND",
+			//the datapoint at the end of the window is returned again by the next window.
+			DataPoints: []*mongodbatlas.DataPoints{
+				{Timestamp: end.Format(time.RFC3339), Value: &value},
+				{Timestamp: start.Format(time.RFC3339), Value: &value},
+			},
+		},
+		{
+			Name:       "TICKETS_AVAILABLE_READS",
+			Units:      "SCALAR",
+			DataPoints: []*mongodbatlas.DataPoints{{Timestamp: start.Format(time.RFC3339)}},
+		},
+	}, nil
+}
+
+func (c *backfillMockClient) GetDiskMeasurementsRange(_ context.Context, _ *measurer.Process, _ *measurer.Disk, start, _ time.Time) ([]*mongodbatlas.Measurements, *a.HTTPError) {
+	value := float32(1.5)
+	return []*mongodbatlas.Measurements{
+		{
+			Name:       "DISK_PARTITION_SPACE_USED",
+			Units:      "KILOBYTES",
+			DataPoints: []*mongodbatlas.DataPoints{{Timestamp: start.Format(time.RFC3339), Value: &value}},
+		},
+	}, nil
+}
+
+func (c *backfillMockClient) AllowedMetricName(string) bool {
+	return true
+}
+
+func (c *backfillMockClient) MapMeasurement(string) (config.MappedMeasurement, bool) {
+	return config.MappedMeasurement{}, false
+}
+
+func (c *backfillMockClient) UnitRule(model.UnitEnum) (config.UnitRule, bool) {
+	return config.UnitRule{}, false
+}
+
+func TestBackfill(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	client := &backfillMockClient{}
+	var out bytes.Buffer
+	err := Backfill(context.Background(), log.NewNopLogger(), client, &out, BackfillOptions{
+		Start:  start,
+		End:    start.Add(2 * time.Minute),
+		Window: time.Minute,
+	})
+	assert.NoError(err)
+
+	assert.Equal([][2]time.Time{
+		{start, start.Add(time.Minute)},
+		{start.Add(time.Minute), start.Add(2 * time.Minute)},
+	}, client.ranges)
+
+	expected := `# HELP mongodbatlas_disks_stats_disk_partition_space_used_bytes Original measurements.name: 'DISK_PARTITION_SPACE_USED'. Used space of the partition.
+# TYPE mongodbatlas_disks_stats_disk_partition_space_used_bytes gauge
+mongodbatlas_disks_stats_disk_partition_space_used_bytes{partition_name="data",project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 1536 1614556800
+mongodbatlas_disks_stats_disk_partition_space_used_bytes{partition_name="data",project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 1536 1614556860
+# HELP mongodbatlas_processes_stats_query_executor_scanned_ratio Original measurements.name: 'QUERY_EXECUTOR_SCANNED'. Index items scanned by queries per second.
+# TYPE mongodbatlas_processes_stats_query_executor_scanned_ratio gauge
+mongodbatlas_processes_stats_query_executor_scanned_ratio{project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 3 1614556800
+mongodbatlas_processes_stats_query_executor_scanned_ratio{project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 3 1614556860
+mongodbatlas_processes_stats_query_executor_scanned_ratio{project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 3 1614556920
+# EOF
+`
+	assert.Equal(expected, out.String())
+}
+
+func TestBackfill_invalidRange(t *testing.T) {
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	err := Backfill(context.Background(), log.NewNopLogger(), &backfillMockClient{}, &bytes.Buffer{}, BackfillOptions{
+		Start: start,
+		End:   start,
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package collector
+
+import (
+	"context"
+	"mongodbatlas_exporter/measurer"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	backupPrefix = "backup"
+
+	backupSnapshotsHelp                  = "Number of Cloud Provider Snapshots of the cluster."
+	backupSnapshotsSizeHelp              = "Total storage size of the cluster's Cloud Provider Snapshots."
+	backupLastSnapshotStatusHelp         = "Is the most recent snapshot of the cluster in the given status."
+	backupLastSuccessfulSnapshotHelp     = "Creation time of the most recent completed snapshot of the cluster, missing if there is none."
+	backupLastSuccessfulSnapshotSizeHelp = "Storage size of the most recent completed snapshot of the cluster."
+	backupRestoreJobsHelp                = "Number of restore jobs of the cluster by state."
+)
+
+// Backup collects the Cloud Provider Snapshots and restore jobs of all clusters of a project
+// that have Cloud Provider Snapshots enabled.
+type Backup struct {
+	scrapeMetrics
+	client    a.Client
+	logger    log.Logger
+	projectID string
+	timeout   time.Duration
+
+	snapshots, snapshotsSize, lastSnapshotStatus       *prometheus.Desc
+	lastSuccessfulSnapshot, lastSuccessfulSnapshotSize *prometheus.Desc
+	restoreJobs                                        *prometheus.Desc
+}
+
+// NewBackupCollector creates the backup collector of a project.
+// timeout is the deadline of its requests when it is not collected with the context of a scrape.
+func NewBackupCollector(logger log.Logger, client a.Client, projectID string, timeout time.Duration) (*Backup, error) {
+	constLabels := prometheus.Labels{"project_id": projectID}
+	labels := (&measurer.Backup{}).PromVariableLabelNames()
+	newDesc := func(name, help string, labels []string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, backupPrefix, name), help, labels, constLabels)
+	}
+
+	return &Backup{
+		scrapeMetrics:              newScrapeMetrics(backupPrefix, constLabels),
+		client:                     client,
+		logger:                     logger,
+		projectID:                  projectID,
+		timeout:                    timeout,
+		snapshots:                  newDesc("snapshots", backupSnapshotsHelp, labels),
+		snapshotsSize:              newDesc("snapshots_size_bytes", backupSnapshotsSizeHelp, labels),
+		lastSnapshotStatus:         newDesc("last_snapshot_status", backupLastSnapshotStatusHelp, append(labels, "status")),
+		lastSuccessfulSnapshot:     newDesc("last_successful_snapshot_timestamp_seconds", backupLastSuccessfulSnapshotHelp, labels),
+		lastSuccessfulSnapshotSize: newDesc("last_successful_snapshot_size_bytes", backupLastSuccessfulSnapshotSizeHelp, labels),
+		restoreJobs:                newDesc("restore_jobs", backupRestoreJobsHelp, append(labels, "state")),
+	}, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *Backup) Describe(ch chan<- *prometheus.Desc) {
+	c.scrapeMetrics.describe(ch)
+	ch <- c.snapshots
+	ch <- c.snapshotsSize
+	ch <- c.lastSnapshotStatus
+	ch <- c.lastSuccessfulSnapshot
+	ch <- c.lastSuccessfulSnapshotSize
+	ch <- c.restoreJobs
+}
+
+// Collect implements prometheus.Collector.
+func (c *Backup) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	c.CollectContext(ctx, ch)
+}
+
+// CollectContext implements ContextCollector.
+func (c *Backup) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	c.totalScrapes.Inc()
+	defer c.scrapeMetrics.collect(ch)
+
+	clusters, err := c.client.ListClusters(ctx, c.projectID)
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "scrape failure", "project", c.projectID, "err", err)
+		c.scrapeFailures.Inc()
+		c.up.Set(0)
+		return
+	}
+
+	//every cluster is fetched before anything is reported, so a failure does not leave partial results.
+	var backups []*measurer.Backup
+	for i := range clusters {
+		cluster := measurer.ClusterFromMongodbAtlasCluster(&clusters[i])
+		if !cluster.ProviderBackupEnabled {
+			continue
+		}
+
+		snapshots, err := c.client.ListSnapshots(ctx, c.projectID, cluster.Name)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "scrape failure", "project", c.projectID, "cluster", cluster.Name, "err", err)
+			c.scrapeFailures.Inc()
+			c.up.Set(0)
+			return
+		}
+		restoreJobs, err := c.client.ListRestoreJobs(ctx, c.projectID, cluster.Name)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "scrape failure", "project", c.projectID, "cluster", cluster.Name, "err", err)
+			c.scrapeFailures.Inc()
+			c.up.Set(0)
+			return
+		}
+		backups = append(backups, measurer.BackupFromMongodbAtlasSnapshots(cluster.Name, snapshots, restoreJobs))
+	}
+	c.up.Set(1)
+
+	for _, backup := range backups {
+		c.collectBackup(backup, ch)
+	}
+}
+
+func (c *Backup) collectBackup(backup *measurer.Backup, ch chan<- prometheus.Metric) {
+	labels := backup.PromVariableLabelValues()
+	gauge := func(desc *prometheus.Desc, value float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	}
+
+	gauge(c.snapshots, float64(backup.Snapshots), labels...)
+	gauge(c.snapshotsSize, backup.SnapshotsSizeBytes, labels...)
+
+	if backup.LastSnapshotStatus != "" {
+		knownStatus := false
+		for _, status := range measurer.SnapshotStatuses {
+			knownStatus = knownStatus || status == backup.LastSnapshotStatus
+			gauge(c.lastSnapshotStatus, boolToFloat(status == backup.LastSnapshotStatus), append(labels, status)...)
+		}
+		if !knownStatus {
+			gauge(c.lastSnapshotStatus, 1, append(labels, backup.LastSnapshotStatus)...)
+		}
+	}
+
+	//without a completed snapshot there is no recovery point, a timestamp of 0 would look like one from 1970.
+	if !backup.LastSuccessfulSnapshot.IsZero() {
+		gauge(c.lastSuccessfulSnapshot, float64(backup.LastSuccessfulSnapshot.Unix()), labels...)
+		gauge(c.lastSuccessfulSnapshotSize, backup.LastSuccessfulSnapshotSizeBytes, labels...)
+	}
+
+	for _, state := range measurer.RestoreJobStates {
+		gauge(c.restoreJobs, float64(backup.RestoreJobs[state]), append(labels, state)...)
+	}
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"context"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func (c *MockClient) ListSnapshots(_ context.Context, _, clusterName string) ([]*mongodbatlas.CloudProviderSnapshot, *a.HTTPError) {
+	return c.givenSnapshots[clusterName], nil
+}
+
+func (c *MockClient) ListRestoreJobs(_ context.Context, _, clusterName string) ([]*mongodbatlas.CloudProviderSnapshotRestoreJob, *a.HTTPError) {
+	return c.givenRestoreJobs[clusterName], nil
+}
+
+//TestBackupCollector checks that the last successful snapshot is reported even if
+//a newer snapshot failed, and that clusters without Cloud Provider Snapshots are skipped.
+func TestBackupCollector(t *testing.T) {
+	enabled, disabled := true, false
+	mock := &MockClient{
+		givenClusters: []mongodbatlas.Cluster{
+			{Name: "cluster0", ProviderBackupEnabled: &enabled},
+			{Name: "cluster1", ProviderBackupEnabled: &disabled},
+		},
+		givenSnapshots: map[string][]*mongodbatlas.CloudProviderSnapshot{
+			"cluster0": {
+				{ID: "1", CreatedAt: "2021-03-01T00:00:00Z", Status: "completed", StorageSizeBytes: 1000},
+				{ID: "2", CreatedAt: "2021-03-02T00:00:00Z", Status: "completed", StorageSizeBytes: 2000},
+				{ID: "3", CreatedAt: "2021-03-03T00:00:00Z", Status: "failed"},
+			},
+			"cluster1": {
+				{ID: "4", CreatedAt: "2021-03-03T00:00:00Z", Status: "completed", StorageSizeBytes: 1000},
+			},
+		},
+		givenRestoreJobs: map[string][]*mongodbatlas.CloudProviderSnapshotRestoreJob{
+			"cluster0": {
+				{ID: "1", FinishedAt: "2021-03-02T01:00:00Z"},
+				{ID: "2", Cancelled: true},
+				{ID: "3"},
+			},
+		},
+	}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	backupCollector, err := NewBackupCollector(logger, mock, "testProjectID", DefaultFetchTimeout)
+	assert.NoError(t, err)
+
+	expected := `
+# HELP mongodbatlas_backup_last_snapshot_status Is the most recent snapshot of the cluster in the given status.
+# TYPE mongodbatlas_backup_last_snapshot_status gauge
+mongodbatlas_backup_last_snapshot_status{cluster_name="cluster0",project_id="testProjectID",status="completed"} 0
+mongodbatlas_backup_last_snapshot_status{cluster_name="cluster0",project_id="testProjectID",status="failed"} 1
+mongodbatlas_backup_last_snapshot_status{cluster_name="cluster0",project_id="testProjectID",status="inProgress"} 0
+mongodbatlas_backup_last_snapshot_status{cluster_name="cluster0",project_id="testProjectID",status="queued"} 0
+# HELP mongodbatlas_backup_last_successful_snapshot_size_bytes Storage size of the most recent completed snapshot of the cluster.
+# TYPE mongodbatlas_backup_last_successful_snapshot_size_bytes gauge
+mongodbatlas_backup_last_successful_snapshot_size_bytes{cluster_name="cluster0",project_id="testProjectID"} 2000
+# HELP mongodbatlas_backup_last_successful_snapshot_timestamp_seconds Creation time of the most recent completed snapshot of the cluster, missing if there is none.
+# TYPE mongodbatlas_backup_last_successful_snapshot_timestamp_seconds gauge
+mongodbatlas_backup_last_successful_snapshot_timestamp_seconds{cluster_name="cluster0",project_id="testProjectID"} 1.6146432e+09
+# HELP mongodbatlas_backup_restore_jobs Number of restore jobs of the cluster by state.
+# TYPE mongodbatlas_backup_restore_jobs gauge
+mongodbatlas_backup_restore_jobs{cluster_name="cluster0",project_id="testProjectID",state="cancelled"} 1
+mongodbatlas_backup_restore_jobs{cluster_name="cluster0",project_id="testProjectID",state="expired"} 0
+mongodbatlas_backup_restore_jobs{cluster_name="cluster0",project_id="testProjectID",state="finished"} 1
+mongodbatlas_backup_restore_jobs{cluster_name="cluster0",project_id="testProjectID",state="in_progress"} 1
+# HELP mongodbatlas_backup_snapshots Number of Cloud Provider Snapshots of the cluster.
+# TYPE mongodbatlas_backup_snapshots gauge
+mongodbatlas_backup_snapshots{cluster_name="cluster0",project_id="testProjectID"} 3
+# HELP mongodbatlas_backup_snapshots_size_bytes Total storage size of the cluster's Cloud Provider Snapshots.
+# TYPE mongodbatlas_backup_snapshots_size_bytes gauge
+mongodbatlas_backup_snapshots_size_bytes{cluster_name="cluster0",project_id="testProjectID"} 3000
+# HELP mongodbatlas_backup_up Was the last communication with MongoDB Atlas API successful.
+# TYPE mongodbatlas_backup_up gauge
+mongodbatlas_backup_up{project_id="testProjectID"} 1
+`
+
+	err = testutil.CollectAndCompare(backupCollector, strings.NewReader(expected),
+		"mongodbatlas_backup_last_snapshot_status", "mongodbatlas_backup_last_successful_snapshot_size_bytes",
+		"mongodbatlas_backup_last_successful_snapshot_timestamp_seconds", "mongodbatlas_backup_restore_jobs",
+		"mongodbatlas_backup_snapshots", "mongodbatlas_backup_snapshots_size_bytes", "mongodbatlas_backup_up")
+	assert.NoError(t, err)
+}
+
+//TestBackupCollector_noCompletedSnapshot checks that a cluster without a completed snapshot
+//has no last successful snapshot series instead of a timestamp of 0.
+func TestBackupCollector_noCompletedSnapshot(t *testing.T) {
+	enabled := true
+	mock := &MockClient{
+		givenClusters: []mongodbatlas.Cluster{{Name: "cluster0", ProviderBackupEnabled: &enabled}},
+		givenSnapshots: map[string][]*mongodbatlas.CloudProviderSnapshot{
+			"cluster0": {{ID: "1", CreatedAt: "2021-03-01T00:00:00Z", Status: "failed"}},
+		},
+	}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	backupCollector, err := NewBackupCollector(logger, mock, "testProjectID", DefaultFetchTimeout)
+	assert.NoError(t, err)
+
+	expected := `
+# HELP mongodbatlas_backup_snapshots Number of Cloud Provider Snapshots of the cluster.
+# TYPE mongodbatlas_backup_snapshots gauge
+mongodbatlas_backup_snapshots{cluster_name="cluster0",project_id="testProjectID"} 1
+`
+	err = testutil.CollectAndCompare(backupCollector, strings.NewReader(expected),
+		"mongodbatlas_backup_last_successful_snapshot_size_bytes", "mongodbatlas_backup_last_successful_snapshot_timestamp_seconds",
+		"mongodbatlas_backup_snapshots")
+	assert.NoError(t, err)
+}
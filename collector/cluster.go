@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"context"
+	"mongodbatlas_exporter/measurer"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	clustersPrefix = "clusters"
+
+	clusterInfoHelp              = "Cluster info metric"
+	clusterStateHelp             = "Is the cluster in the given state."
+	clusterPausedHelp            = "Is the cluster paused."
+	clusterDiskSizeHelp          = "Size of the cluster's disks."
+	clusterShardsHelp            = "Number of shards of the cluster, 1 for replica sets."
+	clusterReplicationFactorHelp = "Number of replica set members of each shard."
+	clusterBackupEnabledHelp     = "Is cloud provider or legacy backup enabled for the cluster."
+	clusterAutoScalingInfoHelp   = "Instance size bounds of the cluster's compute auto scaling."
+	clusterAutoScalingHelp       = "Is auto scaling enabled for the given resource (compute or disk)."
+	clusterAutoScalingAtMaxHelp  = "Is compute auto scaling enabled and the cluster at its maximum instance size."
+)
+
+// Cluster collects the configuration and state of all clusters of a project.
+// Clusters are listed on every scrape, so new clusters show up without a reconcile.
+type Cluster struct {
+	scrapeMetrics
+	client    a.Client
+	logger    log.Logger
+	projectID string
+	timeout   time.Duration
+
+	info, state, paused, diskSize, shards, replicationFactor, backupEnabled *prometheus.Desc
+	autoScalingInfo, autoScaling, autoScalingAtMax                          *prometheus.Desc
+}
+
+// NewClusterCollector creates the cluster collector of a project.
+// timeout is the deadline of its requests when it is not collected with the context of a scrape.
+func NewClusterCollector(logger log.Logger, client a.Client, projectID string, timeout time.Duration) (*Cluster, error) {
+	constLabels := prometheus.Labels{"project_id": projectID}
+	labels := (&measurer.Cluster{}).PromVariableLabelNames()
+	newDesc := func(name, help string, labels []string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, clustersPrefix, name), help, labels, constLabels)
+	}
+
+	return &Cluster{
+		scrapeMetrics:     newScrapeMetrics(clustersPrefix, constLabels),
+		client:            client,
+		logger:            logger,
+		projectID:         projectID,
+		timeout:           timeout,
+		info:              newDesc("info", clusterInfoHelp, (&measurer.Cluster{}).PromInfoLabelNames()),
+		state:             newDesc("state", clusterStateHelp, append(labels, "state")),
+		paused:            newDesc("paused", clusterPausedHelp, labels),
+		diskSize:          newDesc("disk_size_bytes", clusterDiskSizeHelp, labels),
+		shards:            newDesc("shards", clusterShardsHelp, labels),
+		replicationFactor: newDesc("replication_factor", clusterReplicationFactorHelp, labels),
+		backupEnabled:     newDesc("backup_enabled", clusterBackupEnabledHelp, labels),
+		autoScalingInfo:   newDesc("auto_scaling_info", clusterAutoScalingInfoHelp, (&measurer.Cluster{}).PromAutoScalingLabelNames()),
+		autoScaling:       newDesc("auto_scaling_enabled", clusterAutoScalingHelp, append(labels, "resource")),
+		autoScalingAtMax:  newDesc("auto_scaling_at_max", clusterAutoScalingAtMaxHelp, labels),
+	}, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *Cluster) Describe(ch chan<- *prometheus.Desc) {
+	c.scrapeMetrics.describe(ch)
+	ch <- c.info
+	ch <- c.state
+	ch <- c.paused
+	ch <- c.diskSize
+	ch <- c.shards
+	ch <- c.replicationFactor
+	ch <- c.backupEnabled
+	ch <- c.autoScalingInfo
+	ch <- c.autoScaling
+	ch <- c.autoScalingAtMax
+}
+
+// Collect implements prometheus.Collector.
+func (c *Cluster) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	c.CollectContext(ctx, ch)
+}
+
+// CollectContext implements ContextCollector.
+func (c *Cluster) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	c.totalScrapes.Inc()
+	defer c.scrapeMetrics.collect(ch)
+
+	clusters, err := c.client.ListClusters(ctx, c.projectID)
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "scrape failure", "project", c.projectID, "err", err)
+		c.scrapeFailures.Inc()
+		c.up.Set(0)
+		return
+	}
+	c.up.Set(1)
+
+	for i := range clusters {
+		c.collectCluster(measurer.ClusterFromMongodbAtlasCluster(&clusters[i]), ch)
+	}
+}
+
+func (c *Cluster) collectCluster(cluster *measurer.Cluster, ch chan<- prometheus.Metric) {
+	labels := cluster.PromVariableLabelValues()
+	gauge := func(desc *prometheus.Desc, value float64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	}
+
+	gauge(c.info, 1, cluster.PromInfoLabelValues()...)
+
+	knownState := false
+	for _, state := range measurer.ClusterStates {
+		knownState = knownState || state == cluster.StateName
+		gauge(c.state, boolToFloat(state == cluster.StateName), append(labels, state)...)
+	}
+	//states introduced by Atlas after this exporter was written are reported as well.
+	if !knownState && cluster.StateName != "" {
+		gauge(c.state, 1, append(labels, cluster.StateName)...)
+	}
+
+	gauge(c.paused, boolToFloat(cluster.Paused), labels...)
+	gauge(c.diskSize, cluster.DiskSizeBytes, labels...)
+	gauge(c.shards, float64(cluster.NumShards), labels...)
+	gauge(c.replicationFactor, float64(cluster.ReplicationFactor), labels...)
+	gauge(c.backupEnabled, boolToFloat(cluster.BackupEnabled), labels...)
+	gauge(c.autoScaling, boolToFloat(cluster.AutoScalingComputeEnabled), append(labels, "compute")...)
+	gauge(c.autoScaling, boolToFloat(cluster.AutoScalingDiskEnabled), append(labels, "disk")...)
+	gauge(c.autoScalingAtMax, boolToFloat(cluster.AutoScalingAtMax()), labels...)
+
+	if cluster.AutoScalingComputeEnabled {
+		gauge(c.autoScalingInfo, 1, cluster.PromAutoScalingLabelValues()...)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"context"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func (c *MockClient) ListClusters(context.Context, string) ([]mongodbatlas.Cluster, *a.HTTPError) {
+	return c.givenClusters, nil
+}
+
+var clusterExpectedDescs = [][]string{
+	{prometheus.BuildFQName(namespace, clustersPrefix, "up"), upHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "scrapes_total"), totalScrapesHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "scrape_failures_total"), scrapeFailuresHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "info"), clusterInfoHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "state"), clusterStateHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "paused"), clusterPausedHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "disk_size_bytes"), clusterDiskSizeHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "shards"), clusterShardsHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "replication_factor"), clusterReplicationFactorHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "backup_enabled"), clusterBackupEnabledHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "auto_scaling_info"), clusterAutoScalingInfoHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "auto_scaling_enabled"), clusterAutoScalingHelp},
+	{prometheus.BuildFQName(namespace, clustersPrefix, "auto_scaling_at_max"), clusterAutoScalingAtMaxHelp},
+}
+
+func TestClusterDescribe(t *testing.T) {
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	clusterCollector, err := NewClusterCollector(logger, &MockClient{}, "testProjectID", DefaultFetchTimeout)
+	assert.NoError(t, err)
+
+	expectedDescs := make([]*prometheus.Desc, len(clusterExpectedDescs))
+	for i, desc := range clusterExpectedDescs {
+		expectedDescs[i] = prometheus.NewDesc(desc[0], desc[1], nil, prometheus.Labels{"project_id": "testProjectID"})
+	}
+
+	testDescribe(t, clusterCollector, expectedDescs)
+}
+
+func TestClusterCollector(t *testing.T) {
+	enabled, disabled := true, false
+	diskSizeGB := float64(10)
+	numShards, replicationFactor := int64(1), int64(3)
+	mock := &MockClient{
+		givenClusters: []mongodbatlas.Cluster{
+			{
+				GroupID:               "testProjectID",
+				Name:                  "cluster0",
+				ClusterType:           "REPLICASET",
+				StateName:             "UPDATING",
+				MongoDBVersion:        "4.4.10",
+				Paused:                &disabled,
+				ProviderBackupEnabled: &enabled,
+				DiskSizeGB:            &diskSizeGB,
+				NumShards:             &numShards,
+				ReplicationFactor:     &replicationFactor,
+				AutoScaling: &mongodbatlas.AutoScaling{
+					Compute:       &mongodbatlas.Compute{Enabled: &enabled},
+					DiskGBEnabled: &disabled,
+				},
+				ProviderSettings: &mongodbatlas.ProviderSettings{
+					InstanceSizeName: "M30",
+					ProviderName:     "AWS",
+					RegionName:       "EU_CENTRAL_1",
+					AutoScaling: &mongodbatlas.AutoScaling{
+						Compute: &mongodbatlas.Compute{MinInstanceSize: "M10", MaxInstanceSize: "M30"},
+					},
+				},
+			},
+		},
+	}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	clusterCollector, err := NewClusterCollector(logger, mock, "testProjectID", DefaultFetchTimeout)
+	assert.NoError(t, err)
+
+	expected := `
+# HELP mongodbatlas_clusters_info Cluster info metric
+# TYPE mongodbatlas_clusters_info gauge
+mongodbatlas_clusters_info{cluster_name="cluster0",cluster_type="REPLICASET",instance_size="M30",mongodb_version="4.4.10",project_id="testProjectID",provider="AWS",region="EU_CENTRAL_1"} 1
+# HELP mongodbatlas_clusters_state Is the cluster in the given state.
+# TYPE mongodbatlas_clusters_state gauge
+mongodbatlas_clusters_state{cluster_name="cluster0",project_id="testProjectID",state="CREATING"} 0
+mongodbatlas_clusters_state{cluster_name="cluster0",project_id="testProjectID",state="DELETED"} 0
+mongodbatlas_clusters_state{cluster_name="cluster0",project_id="testProjectID",state="DELETING"} 0
+mongodbatlas_clusters_state{cluster_name="cluster0",project_id="testProjectID",state="IDLE"} 0
+mongodbatlas_clusters_state{cluster_name="cluster0",project_id="testProjectID",state="REPAIRING"} 0
+mongodbatlas_clusters_state{cluster_name="cluster0",project_id="testProjectID",state="UPDATING"} 1
+# HELP mongodbatlas_clusters_paused Is the cluster paused.
+# TYPE mongodbatlas_clusters_paused gauge
+mongodbatlas_clusters_paused{cluster_name="cluster0",project_id="testProjectID"} 0
+# HELP mongodbatlas_clusters_disk_size_bytes Size of the cluster's disks.
+# TYPE mongodbatlas_clusters_disk_size_bytes gauge
+mongodbatlas_clusters_disk_size_bytes{cluster_name="cluster0",project_id="testProjectID"} 1.073741824e+10
+# HELP mongodbatlas_clusters_shards Number of shards of the cluster, 1 for replica sets.
+# TYPE mongodbatlas_clusters_shards gauge
+mongodbatlas_clusters_shards{cluster_name="cluster0",project_id="testProjectID"} 1
+# HELP mongodbatlas_clusters_replication_factor Number of replica set members of each shard.
+# TYPE mongodbatlas_clusters_replication_factor gauge
+mongodbatlas_clusters_replication_factor{cluster_name="cluster0",project_id="testProjectID"} 3
+# HELP mongodbatlas_clusters_backup_enabled Is cloud provider or legacy backup enabled for the cluster.
+# TYPE mongodbatlas_clusters_backup_enabled gauge
+mongodbatlas_clusters_backup_enabled{cluster_name="cluster0",project_id="testProjectID"} 1
+# HELP mongodbatlas_clusters_auto_scaling_enabled Is auto scaling enabled for the given resource (compute or disk).
+# TYPE mongodbatlas_clusters_auto_scaling_enabled gauge
+mongodbatlas_clusters_auto_scaling_enabled{cluster_name="cluster0",project_id="testProjectID",resource="compute"} 1
+mongodbatlas_clusters_auto_scaling_enabled{cluster_name="cluster0",project_id="testProjectID",resource="disk"} 0
+# HELP mongodbatlas_clusters_auto_scaling_info Instance size bounds of the cluster's compute auto scaling.
+# TYPE mongodbatlas_clusters_auto_scaling_info gauge
+mongodbatlas_clusters_auto_scaling_info{cluster_name="cluster0",max_instance_size="M30",min_instance_size="M10",project_id="testProjectID"} 1
+# HELP mongodbatlas_clusters_auto_scaling_at_max Is compute auto scaling enabled and the cluster at its maximum instance size.
+# TYPE mongodbatlas_clusters_auto_scaling_at_max gauge
+mongodbatlas_clusters_auto_scaling_at_max{cluster_name="cluster0",project_id="testProjectID"} 1
+# HELP mongodbatlas_clusters_up Was the last communication with MongoDB Atlas API successful.
+# TYPE mongodbatlas_clusters_up gauge
+mongodbatlas_clusters_up{project_id="testProjectID"} 1
+`
+
+	err = testutil.CollectAndCompare(clusterCollector, strings.NewReader(expected),
+		"mongodbatlas_clusters_info",
+		"mongodbatlas_clusters_state",
+		"mongodbatlas_clusters_paused",
+		"mongodbatlas_clusters_disk_size_bytes",
+		"mongodbatlas_clusters_shards",
+		"mongodbatlas_clusters_replication_factor",
+		"mongodbatlas_clusters_backup_enabled",
+		"mongodbatlas_clusters_auto_scaling_enabled",
+		"mongodbatlas_clusters_auto_scaling_info",
+		"mongodbatlas_clusters_auto_scaling_at_max",
+		"mongodbatlas_clusters_up",
+	)
+	assert.NoError(t, err)
+}
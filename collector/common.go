@@ -4,10 +4,16 @@ import (
 	"fmt"
 	transformer "mongodbatlas_exporter/collector/transformer"
 	"mongodbatlas_exporter/measurer"
+	m "mongodbatlas_exporter/model"
 	a "mongodbatlas_exporter/mongodbatlas"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 const (
@@ -17,38 +23,88 @@ const (
 	totalScrapesHelp                      = "Current total MongoDB Atlas scrapes."
 	scrapeFailuresHelp                    = "Number of unsuccessful measurement scrapes from MongoDB Atlas API."
 	measurementTransformationFailuresHelp = "Number of errors during transformation of scraped MongoDB Atlas measurements into Prometheus metrics."
+	unknownUnitMeasurementsHelp           = "Number of measurements exported with a unit unknown to the exporter, add a rule to units in the configuration file to convert them."
 )
 
-type basicCollector struct {
-	client a.Client
-	logger log.Logger
+//unknownUnitMeasurements counts every build of a metric whose unit is unknown, so new Atlas units get noticed.
+var unknownUnitMeasurements = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: prometheus.BuildFQName(namespace, "", "unknown_unit_measurements"),
+	Help: unknownUnitMeasurementsHelp,
+}, []string{"atlas_metric", "unit"})
 
-	up                                prometheus.Gauge
-	totalScrapes, scrapeFailures      prometheus.Counter
-	measurementTransformationFailures prometheus.CounterVec
-	measurer                          measurer.Measurer
+//countUnknownUnits increments unknownUnitMeasurements for the metrics of a measurer with an unknown unit.
+func countUnknownUnits(metrics []*measurer.PromMetric) {
+	for _, metric := range metrics {
+		if metric.UnknownUnit {
+			unknownUnitMeasurements.WithLabelValues(metric.Metadata.Name, string(metric.Metadata.Units)).Inc()
+		}
+	}
 }
 
-// newBasicCollector creates basicCollector
-func newBasicCollector(logger log.Logger, client a.Client, measurer measurer.Measurer, collectorPrefix string) (*basicCollector, error) {
-	failureLabels := []string{"atlas_metric", "error"}
+//scrapeMetrics are reported by every collector about its own scrapes.
+type scrapeMetrics struct {
+	up                           prometheus.Gauge
+	totalScrapes, scrapeFailures prometheus.Counter
+}
 
-	return &basicCollector{
+func newScrapeMetrics(collectorPrefix string, constLabels prometheus.Labels) scrapeMetrics {
+	return scrapeMetrics{
 		up: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name:        prometheus.BuildFQName(namespace, collectorPrefix, "up"),
 			Help:        upHelp,
-			ConstLabels: measurer.PromConstLabels(),
+			ConstLabels: constLabels,
 		}),
 		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
 			Name:        prometheus.BuildFQName(namespace, collectorPrefix, "scrapes_total"),
 			Help:        totalScrapesHelp,
-			ConstLabels: measurer.PromConstLabels(),
+			ConstLabels: constLabels,
 		}),
 		scrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{
 			Name:        prometheus.BuildFQName(namespace, collectorPrefix, "scrape_failures_total"),
 			Help:        scrapeFailuresHelp,
-			ConstLabels: measurer.PromConstLabels(),
+			ConstLabels: constLabels,
 		}),
+	}
+}
+
+func (s *scrapeMetrics) describe(ch chan<- *prometheus.Desc) {
+	ch <- s.up.Desc()
+	ch <- s.totalScrapes.Desc()
+	ch <- s.scrapeFailures.Desc()
+}
+
+func (s *scrapeMetrics) collect(ch chan<- prometheus.Metric) {
+	ch <- s.up
+	ch <- s.totalScrapes
+	ch <- s.scrapeFailures
+}
+
+type basicCollector struct {
+	scrapeMetrics
+	client a.Client
+	logger log.Logger
+
+	measurementTransformationFailures prometheus.CounterVec
+	measurer                          measurer.Measurer
+
+	//timestamps makes report emit the timestamps of the Atlas datapoints instead of the scrape time.
+	timestamps bool
+	//emitted holds the timestamp of the latest datapoint reported for each series,
+	//so a datapoint is reported only once.
+	emittedMu sync.Mutex
+	emitted   map[string]time.Time
+	//aggregates makes report emit the minimum, maximum and average of the datapoints as well.
+	aggregates bool
+	//counters integrates rate measurements into counters, it is nil if they are disabled.
+	counters *rateCounters
+}
+
+// newBasicCollector creates basicCollector
+func newBasicCollector(logger log.Logger, client a.Client, measurer measurer.Measurer, collectorPrefix string) (*basicCollector, error) {
+	failureLabels := []string{"atlas_metric", "error"}
+
+	return &basicCollector{
+		scrapeMetrics: newScrapeMetrics(collectorPrefix, measurer.PromConstLabels()),
 		measurementTransformationFailures: *prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name:        prometheus.BuildFQName(namespace, collectorPrefix, "measurement_transformation_failures_total"),
 			Help:        measurementTransformationFailuresHelp,
@@ -57,18 +113,30 @@ func newBasicCollector(logger log.Logger, client a.Client, measurer measurer.Mea
 		client:   client,
 		measurer: measurer,
 		logger:   logger,
+		emitted:  make(map[string]time.Time),
 	}, nil
 }
 
 // Describe implements prometheus.Collector.
 func (c *basicCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.up.Desc()
-	ch <- c.totalScrapes.Desc()
-	ch <- c.scrapeFailures.Desc()
+	c.scrapeMetrics.describe(ch)
 	c.measurementTransformationFailures.Describe(ch)
 
-	for _, metric := range c.measurer.PromMetrics() {
+	c.describeMetrics(c.measurer.PromMetrics(), ch)
+}
+
+//describeMetrics sends the descriptions of the measurement metrics, including their aggregates if enabled.
+func (c *basicCollector) describeMetrics(metrics []*measurer.PromMetric, ch chan<- *prometheus.Desc) {
+	for _, metric := range metrics {
 		ch <- metric.Desc
+		if c.aggregates {
+			ch <- metric.Min
+			ch <- metric.Max
+			ch <- metric.Avg
+		}
+		if c.counters != nil && metric.Counter != nil {
+			ch <- metric.Counter
+		}
 	}
 }
 
@@ -102,7 +170,7 @@ func (c *basicCollector) report(measurer measurer.Measurer, metric *measurer.Pro
 		ch <- notRegistered
 		return fmt.Errorf("instance has no measurement %s", metric.Metadata.Name)
 	}
-	value, err := transformer.TransformValue(measurement)
+	value, timestamp, err := transformer.TransformValueWithTimestamp(measurement)
 	//exposing different value transformation errors as metrics.
 	//this is a nice example of using errors with switch statements
 	if err != nil {
@@ -120,11 +188,90 @@ func (c *basicCollector) report(measurer measurer.Measurer, metric *measurer.Pro
 		return err
 	}
 
-	ch <- prometheus.MustNewConstMetric(
+	promMetric := prometheus.MustNewConstMetric(
 		metric.Desc,
 		metric.Type,
-		value,
+		metric.Scale(value),
 		measurer.PromVariableLabelValues()...,
 	)
+	if c.timestamps {
+		//without a datapoint there is nothing new to report.
+		if timestamp.IsZero() || !c.markEmitted(metric.Desc, measurer.PromVariableLabelValues(), timestamp) {
+			return nil
+		}
+		promMetric = prometheus.NewMetricWithTimestamp(timestamp, promMetric)
+	}
+	ch <- promMetric
+
+	if c.aggregates {
+		c.reportAggregates(measurement, metric, measurer.PromVariableLabelValues(), timestamp, ch)
+	}
+	if c.counters != nil && metric.Counter != nil {
+		c.reportCounter(measurement, metric, measurer.PromVariableLabelValues(), ch)
+	}
 	return nil
 }
+
+//reportCounter emits the counter integrating a rate measurement,
+//with the timestamp of its latest datapoint if timestamps are enabled.
+func (c *basicCollector) reportCounter(measurement *m.Measurement, metric *measurer.PromMetric, labelValues []string, ch chan<- prometheus.Metric) {
+	increments, err := transformer.TransformIncrements(measurement)
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "skipping counter", "metric", metric.Counter, "err", err)
+		return
+	}
+
+	total, timestamp := c.counters.add(seriesKey(metric.Counter, labelValues), increments)
+	if timestamp.IsZero() {
+		return
+	}
+	promMetric := prometheus.MustNewConstMetric(metric.Counter, prometheus.CounterValue, total, labelValues...)
+	if c.timestamps {
+		promMetric = prometheus.NewMetricWithTimestamp(timestamp, promMetric)
+	}
+	ch <- promMetric
+}
+
+//reportAggregates emits the minimum, maximum and average of the datapoints of a measurement,
+//with the timestamp of the latest datapoint if timestamps are enabled.
+func (c *basicCollector) reportAggregates(measurement *m.Measurement, metric *measurer.PromMetric, labelValues []string, timestamp time.Time, ch chan<- prometheus.Metric) {
+	summary, err := transformer.TransformSummary(measurement)
+	if err != nil {
+		return
+	}
+
+	aggregates := []struct {
+		desc  *prometheus.Desc
+		value float64
+	}{
+		{metric.Min, metric.Scale(summary.Min)},
+		{metric.Max, metric.Scale(summary.Max)},
+		{metric.Avg, metric.Scale(summary.Avg)},
+	}
+	for _, aggregate := range aggregates {
+		promMetric := prometheus.MustNewConstMetric(aggregate.desc, prometheus.GaugeValue, aggregate.value, labelValues...)
+		if c.timestamps {
+			promMetric = prometheus.NewMetricWithTimestamp(timestamp, promMetric)
+		}
+		ch <- promMetric
+	}
+}
+
+//markEmitted reports whether the datapoint at timestamp is newer than the last one reported
+//for the series and remembers it if so.
+func (c *basicCollector) markEmitted(desc *prometheus.Desc, labelValues []string, timestamp time.Time) bool {
+	key := seriesKey(desc, labelValues)
+
+	c.emittedMu.Lock()
+	defer c.emittedMu.Unlock()
+	if !timestamp.After(c.emitted[key]) {
+		return false
+	}
+	c.emitted[key] = timestamp
+	return true
+}
+
+//seriesKey identifies a series of the collector.
+func seriesKey(desc *prometheus.Desc, labelValues []string) string {
+	return desc.String() + "\xff" + strings.Join(labelValues, "\xff")
+}
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"mongodbatlas_exporter/config"
 	"mongodbatlas_exporter/measurer"
 	"mongodbatlas_exporter/model"
 	"os"
@@ -9,7 +10,9 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
 )
 
 //Tuples of FQNAME and Metric help
@@ -43,6 +46,19 @@ func getExpectedDescs(measurer measurer.Measurer, expected [][]string) []*promet
 type MockClient struct {
 	givenDisksMeasurements     map[model.MeasurementID]*model.Measurement
 	givenProcessesMeasurements map[model.MeasurementID]*model.Measurement
+	givenClusters              []mongodbatlas.Cluster
+	givenAlerts                []mongodbatlas.Alert
+	givenEvents                []*mongodbatlas.Event
+	//givenSnapshots and givenRestoreJobs are keyed by cluster name.
+	givenSnapshots             map[string][]*mongodbatlas.CloudProviderSnapshot
+	givenRestoreJobs           map[string][]*mongodbatlas.CloudProviderSnapshotRestoreJob
+	givenSuggestedIndexes      []*mongodbatlas.SuggestedIndex
+	givenSlowQueries           []*mongodbatlas.SlowQuery
+	givenDatabases             []*mongodbatlas.ProcessDatabase
+	givenDatabasesMeasurements map[model.MeasurementID]*model.Measurement
+	//givenDeniedMetricNames are the fully qualified metric names the name filter rejects.
+	givenDeniedMetricNames map[string]bool
+	givenMappings          []config.Mapping
 }
 
 type promTestMetric struct {
@@ -190,3 +206,15 @@ func testDescribe(t *testing.T, collector prometheus.Collector, expectedDescs []
 
 	assert.Equal(t, len(expectedDescsMap), len(resultingDescsMap))
 }
+
+func TestCountUnknownUnits(t *testing.T) {
+	counter := unknownUnitMeasurements.WithLabelValues("NEW_MEASUREMENT", "WIDGETS")
+	before := testutil.ToFloat64(counter)
+
+	countUnknownUnits([]*measurer.PromMetric{
+		{Metadata: &model.MeasurementMetadata{Name: "NEW_MEASUREMENT", Units: "WIDGETS"}, UnknownUnit: true},
+		{Metadata: &model.MeasurementMetadata{Name: "CONNECTIONS", Units: model.SCALAR}},
+	})
+
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+}
@@ -0,0 +1,52 @@
+package collector
+
+import (
+	transformer "mongodbatlas_exporter/collector/transformer"
+	"sync"
+	"time"
+)
+
+//rateCounters integrates rate measurements into counters, by series.
+type rateCounters struct {
+	mu       sync.Mutex
+	counters map[string]*rateCounter
+}
+
+type rateCounter struct {
+	total float64
+	//last is the timestamp of the latest integrated datapoint.
+	last time.Time
+}
+
+func newRateCounters() *rateCounters {
+	return &rateCounters{counters: make(map[string]*rateCounter)}
+}
+
+//add integrates the increments newer than the latest integrated datapoint of the series
+//and returns the value of the counter and the timestamp of its latest datapoint.
+//Datapoints without value, e.g. while Atlas did not reach the process, are skipped and the counter keeps its total.
+//Atlas exposes no uptime of a process, the restart signal is a negative rate: the counter of the process
+//itself went down, so the counter is reset to zero like it.
+func (r *rateCounters) add(key string, increments []transformer.Increment) (float64, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counter, ok := r.counters[key]
+	if !ok {
+		counter = &rateCounter{}
+		r.counters[key] = counter
+	}
+
+	for _, increment := range increments {
+		if !increment.Valid || !increment.Timestamp.After(counter.last) {
+			continue
+		}
+		if increment.Value < 0 {
+			counter.total = 0
+		} else {
+			counter.total += increment.Value
+		}
+		counter.last = increment.Timestamp
+	}
+	return counter.total, counter.last
+}
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	transformer "mongodbatlas_exporter/collector/transformer"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateCounters(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2021, 3, 7, 15, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	counters := newRateCounters()
+
+	total, last := counters.add("series", []transformer.Increment{
+		{Timestamp: at(0), Value: 60, Valid: true},
+		{Timestamp: at(1), Value: 120, Valid: true},
+		//the latest datapoint is often not computed yet.
+		{Timestamp: at(2)},
+	})
+	assert.Equal(float64(180), total)
+	assert.Equal(at(1), last)
+
+	//overlapping periods integrate every datapoint once.
+	total, last = counters.add("series", []transformer.Increment{
+		{Timestamp: at(1), Value: 120, Valid: true},
+		{Timestamp: at(2), Value: 30, Valid: true},
+	})
+	assert.Equal(float64(210), total)
+	assert.Equal(at(2), last)
+
+	//gaps in the datapoints are skipped, the counter continues.
+	total, last = counters.add("series", []transformer.Increment{
+		{Timestamp: at(3)},
+		{Timestamp: at(4), Value: 10, Valid: true},
+	})
+	assert.Equal(float64(220), total)
+	assert.Equal(at(4), last)
+
+	//a negative rate means the process restarted, its counters start over.
+	total, _ = counters.add("series", []transformer.Increment{
+		{Timestamp: at(5), Value: -5, Valid: true},
+		{Timestamp: at(6), Value: 20, Valid: true},
+	})
+	assert.Equal(float64(20), total)
+
+	//series are independent.
+	total, last = counters.add("other", nil)
+	assert.Equal(float64(0), total)
+	assert.True(last.IsZero())
+}
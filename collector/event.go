@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"context"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+const (
+	eventsPrefix = "events"
+
+	eventsHelp = "Number of Atlas project events by event type."
+)
+
+// Poller is implemented by collectors that fetch their data on the registerer's
+// reconcile loop instead of on every scrape.
+type Poller interface {
+	Poll()
+}
+
+// Event counts the events of a project, such as primary elections or restarts.
+type Event struct {
+	scrapeMetrics
+	client    a.Client
+	logger    log.Logger
+	projectID string
+	marks     *EventMarks
+	timeout   time.Duration
+
+	events *prometheus.CounterVec
+}
+
+// NewEventCollector creates the event collector of a project.
+// Events are only fetched by Poll, Collect reports the counters as they are.
+// timeout is the deadline of the requests of a poll, which runs on the reconcile of the projects.
+func NewEventCollector(logger log.Logger, client a.Client, projectID string, marks *EventMarks, timeout time.Duration) (*Event, error) {
+	constLabels := prometheus.Labels{"project_id": projectID}
+
+	return &Event{
+		scrapeMetrics: newScrapeMetrics(eventsPrefix, constLabels),
+		client:        client,
+		logger:        logger,
+		projectID:     projectID,
+		marks:         marks,
+		timeout:       timeout,
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        prometheus.BuildFQName(namespace, "", "events_total"),
+			Help:        eventsHelp,
+			ConstLabels: constLabels,
+		}, []string{"event_type"}),
+	}, nil
+}
+
+// Describe implements prometheus.Collector.
+func (c *Event) Describe(ch chan<- *prometheus.Desc) {
+	c.scrapeMetrics.describe(ch)
+	c.events.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Event) Collect(ch chan<- prometheus.Metric) {
+	c.scrapeMetrics.collect(ch)
+	c.events.Collect(ch)
+}
+
+// Poll counts the events created since the project's high-water mark and moves the mark.
+// Without a mark, e.g. on the very first start, counting starts now instead of with the whole history.
+func (c *Event) Poll() {
+	mark, ok := c.marks.Get(c.projectID)
+	if !ok {
+		c.setMark(EventMark{Created: time.Now().UTC().Truncate(time.Second)})
+		return
+	}
+
+	c.totalScrapes.Inc()
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	events, err := c.client.ListEvents(ctx, c.projectID, mark.Created)
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "poll failure", "project", c.projectID, "err", err)
+		c.scrapeFailures.Inc()
+		c.up.Set(0)
+		return
+	}
+	c.up.Set(1)
+
+	created := make(map[*mongodbatlas.Event]time.Time, len(events))
+	for _, event := range events {
+		t, err := time.Parse(time.RFC3339, event.Created)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "skipping event with invalid creation time", "project", c.projectID, "event", event.ID, "err", err)
+			continue
+		}
+		created[event] = t.Truncate(time.Second)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return created[events[i]].Before(created[events[j]])
+	})
+
+	newMark := mark
+	for _, event := range events {
+		t, ok := created[event]
+		if !ok || mark.counted(event.ID, t) {
+			continue
+		}
+
+		c.events.WithLabelValues(event.EventTypeName).Inc()
+
+		if t.After(newMark.Created) {
+			newMark = EventMark{Created: t}
+		}
+		newMark.IDs = append(newMark.IDs, event.ID)
+	}
+
+	c.setMark(newMark)
+}
+
+func (c *Event) setMark(mark EventMark) {
+	if err := c.marks.Set(c.projectID, mark); err != nil {
+		level.Error(c.logger).Log("msg", "failed to persist events high-water mark", "project", c.projectID, "err", err)
+	}
+}
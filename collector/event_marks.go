@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// EventMark is the high-water mark of the counted events of a project.
+// Atlas timestamps have a resolution of a second, so the ids of the events
+// created at exactly Created are kept to not count them twice.
+type EventMark struct {
+	Created time.Time `json:"created"`
+	IDs     []string  `json:"ids"`
+}
+
+// counted reports whether an event was already counted.
+func (m EventMark) counted(id string, created time.Time) bool {
+	if created.Before(m.Created) {
+		return true
+	}
+	if created.After(m.Created) {
+		return false
+	}
+	for _, countedID := range m.IDs {
+		if countedID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// EventMarks holds the high-water marks of all projects and persists them to a file,
+// so restarts of the exporter do not count events twice.
+type EventMarks struct {
+	mu       sync.Mutex
+	filename string
+	marks    map[string]EventMark
+}
+
+// LoadEventMarks reads the high-water marks from filename.
+// A missing file is not an error. If filename is empty the marks are only kept in memory.
+func LoadEventMarks(filename string) (*EventMarks, error) {
+	marks := &EventMarks{
+		filename: filename,
+		marks:    make(map[string]EventMark),
+	}
+	if filename == "" {
+		return marks, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return marks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &marks.marks); err != nil {
+		return nil, err
+	}
+	return marks, nil
+}
+
+// Get returns the high-water mark of a project.
+func (m *EventMarks) Get(projectID string) (EventMark, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mark, ok := m.marks[projectID]
+	return mark, ok
+}
+
+// Set updates the high-water mark of a project and persists all marks.
+func (m *EventMarks) Set(projectID string, mark EventMark) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.marks[projectID] = mark
+
+	if m.filename == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(m.marks)
+	if err != nil {
+		return err
+	}
+
+	//write to a temporary file first, so a crash never leaves a truncated file behind.
+	tmp, err := ioutil.TempFile(filepath.Dir(m.filename), filepath.Base(m.filename))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.filename)
+}
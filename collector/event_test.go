@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func (c *MockClient) ListEvents(ctx context.Context, _ string, minDate time.Time) ([]*mongodbatlas.Event, *a.HTTPError) {
+	//a poll without deadline could stall the reconcile of every project.
+	if _, ok := ctx.Deadline(); !ok {
+		return nil, &a.HTTPError{Err: errors.New("events listed without deadline")}
+	}
+	var events []*mongodbatlas.Event
+	for _, event := range c.givenEvents {
+		created, _ := time.Parse(time.RFC3339, event.Created)
+		if !created.Before(minDate) {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+//TestEventCollector checks that events are counted once, also across restarts of the exporter.
+func TestEventCollector(t *testing.T) {
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	stateFile := filepath.Join(t.TempDir(), "events.json")
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	marks, err := LoadEventMarks(stateFile)
+	assert.NoError(t, err)
+	assert.NoError(t, marks.Set("testProjectID", EventMark{Created: start}))
+
+	mock := &MockClient{
+		givenEvents: []*mongodbatlas.Event{
+			{ID: "1", EventTypeName: "CLUSTER_READY", Created: "2021-03-01T09:59:59Z"},
+			{ID: "2", EventTypeName: "PRIMARY_ELECTED", Created: "2021-03-01T10:00:00Z"},
+			{ID: "3", EventTypeName: "PRIMARY_ELECTED", Created: "2021-03-01T10:01:00Z"},
+		},
+	}
+
+	eventCollector, err := NewEventCollector(logger, mock, "testProjectID", marks, time.Minute)
+	assert.NoError(t, err)
+	eventCollector.Poll()
+	//events at the high-water mark must not be counted again
+	eventCollector.Poll()
+
+	expected := `
+# HELP mongodbatlas_events_total Number of Atlas project events by event type.
+# TYPE mongodbatlas_events_total counter
+mongodbatlas_events_total{event_type="PRIMARY_ELECTED",project_id="testProjectID"} 2
+`
+	err = testutil.CollectAndCompare(eventCollector, strings.NewReader(expected), "mongodbatlas_events_total")
+	assert.NoError(t, err)
+
+	//a restarted exporter continues at the persisted mark
+	mock.givenEvents = append(mock.givenEvents,
+		&mongodbatlas.Event{ID: "4", EventTypeName: "CLUSTER_UPDATE_COMPLETED", Created: "2021-03-01T10:01:00Z"})
+	marks, err = LoadEventMarks(stateFile)
+	assert.NoError(t, err)
+	eventCollector, err = NewEventCollector(logger, mock, "testProjectID", marks, time.Minute)
+	assert.NoError(t, err)
+	eventCollector.Poll()
+
+	expected = `
+# HELP mongodbatlas_events_total Number of Atlas project events by event type.
+# TYPE mongodbatlas_events_total counter
+mongodbatlas_events_total{event_type="CLUSTER_UPDATE_COMPLETED",project_id="testProjectID"} 1
+`
+	err = testutil.CollectAndCompare(eventCollector, strings.NewReader(expected), "mongodbatlas_events_total")
+	assert.NoError(t, err)
+}
+
+//TestEventCollector_firstPoll checks that the history before the first poll is not counted.
+func TestEventCollector_firstPoll(t *testing.T) {
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	marks, err := LoadEventMarks("")
+	assert.NoError(t, err)
+
+	mock := &MockClient{
+		givenEvents: []*mongodbatlas.Event{
+			{ID: "1", EventTypeName: "CLUSTER_READY", Created: "2021-03-01T09:59:59Z"},
+		},
+	}
+	eventCollector, err := NewEventCollector(logger, mock, "testProjectID", marks, time.Minute)
+	assert.NoError(t, err)
+	eventCollector.Poll()
+	eventCollector.Poll()
+
+	assert.Equal(t, 0, testutil.CollectAndCount(eventCollector, "mongodbatlas_events_total"))
+	_, ok := marks.Get("testProjectID")
+	assert.True(t, ok)
+}
@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//DefaultFetchTimeout is the deadline of the measurement requests if no scrape timeout is known.
+const DefaultFetchTimeout = 30 * time.Second
+
+// FetchPool bounds the number of measurement requests all process collectors send at the same time.
+// Prometheus collects every registered collector in parallel, without the pool a scrape of a large
+// sharded cluster sends a request for every process, disk and database at once.
+type FetchPool struct {
+	workers chan struct{}
+	//timeout is the deadline of background polls and of scrapes without a timeout header.
+	timeout time.Duration
+}
+
+// NewFetchPool creates a pool of concurrency workers.
+func NewFetchPool(concurrency int, timeout time.Duration) *FetchPool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout
+	}
+	return &FetchPool{
+		workers: make(chan struct{}, concurrency),
+		timeout: timeout,
+	}
+}
+
+// fetchContext returns the context of the requests of a background poll
+// and of a Collect without the context of a scrape.
+func (p *FetchPool) fetchContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), p.timeout)
+}
+
+// run calls every task on a worker of the pool and returns when all are done.
+// Tasks still waiting for a worker when ctx is done are called right away,
+// their requests fail immediately.
+func (p *FetchPool) run(ctx context.Context, tasks []func(context.Context)) {
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task func(context.Context)) {
+			defer wg.Done()
+			select {
+			case p.workers <- struct{}{}:
+				defer func() { <-p.workers }()
+			case <-ctx.Done():
+			}
+			task(ctx)
+		}(task)
+	}
+	wg.Wait()
+}
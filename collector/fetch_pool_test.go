@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchPool(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewFetchPool(2, time.Second)
+
+	var running, maxRunning int32
+	tasks := make([]func(context.Context), 6)
+	for i := range tasks {
+		tasks[i] = func(context.Context) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}
+	}
+
+	pool.run(context.Background(), tasks)
+
+	assert.Equal(int32(2), maxRunning)
+	assert.Equal(int32(0), running)
+}
+
+// TestFetchPool_done checks that tasks waiting for a worker are not held up once the scrape is over.
+func TestFetchPool_done(t *testing.T) {
+	pool := NewFetchPool(1, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var called int32
+	block := func(ctx context.Context) {
+		atomic.AddInt32(&called, 1)
+		<-ctx.Done()
+	}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	pool.run(ctx, []func(context.Context){block, block, block})
+
+	assert.Equal(t, int32(3), called)
+}
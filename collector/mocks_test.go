@@ -1,6 +1,8 @@
 package collector
 
 import (
+	"context"
+	"mongodbatlas_exporter/config"
 	"mongodbatlas_exporter/measurer"
 	"mongodbatlas_exporter/model"
 	a "mongodbatlas_exporter/mongodbatlas"
@@ -8,12 +10,12 @@ import (
 	"go.mongodb.org/atlas/mongodbatlas"
 )
 
-func (c *MockClient) GetDiskMeasurements(_ *measurer.Process, d *measurer.Disk) error {
+func (c *MockClient) GetDiskMeasurements(_ context.Context, _ *measurer.Process, d *measurer.Disk) error {
 	d.Measurements = c.givenDisksMeasurements
 	return nil
 }
 
-func (c *MockClient) GetDiskMeasurementsMetadata(_ *measurer.Process, _ *measurer.Disk) (map[model.MeasurementID]*model.MeasurementMetadata, error) {
+func (c *MockClient) GetDiskMeasurementsMetadata(_ context.Context, _ *measurer.Process, _ *measurer.Disk) (map[model.MeasurementID]*model.MeasurementMetadata, error) {
 	return map[model.MeasurementID]*model.MeasurementMetadata{
 		model.NewMeasurementID("DISK_PARTITION_IOPS_READ", "SCALAR_PER_SECOND"): {
 			Name:  "DISK_PARTITION_IOPS_READ",
@@ -26,17 +28,17 @@ func (c *MockClient) GetDiskMeasurementsMetadata(_ *measurer.Process, _ *measure
 	}, nil
 }
 
-func (c *MockClient) GetProcessMeasurements(_ measurer.Process) (map[model.MeasurementID]*model.Measurement, error) {
+func (c *MockClient) GetProcessMeasurements(_ context.Context, _ measurer.Process) (map[model.MeasurementID]*model.Measurement, error) {
 	return c.givenProcessesMeasurements, nil
 }
 
-func (c *MockClient) ListDisks(*mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *a.HTTPError) {
+func (c *MockClient) ListDisks(context.Context, *mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *a.HTTPError) {
 	return []*mongodbatlas.ProcessDisk{
 		{PartitionName: "data"},
 	}, nil
 }
 
-func (c *MockClient) GetProcessMeasurementsMetadata(p *measurer.Process) *a.HTTPError {
+func (c *MockClient) GetProcessMeasurementsMetadata(_ context.Context, p *measurer.Process) *a.HTTPError {
 	p.Metadata = map[model.MeasurementID]*model.MeasurementMetadata{
 		model.NewMeasurementID("TICKETS_AVAILABLE_READS", "SCALAR"): {
 			Name:  "TICKETS_AVAILABLE_READS",
@@ -50,6 +52,44 @@ func (c *MockClient) GetProcessMeasurementsMetadata(p *measurer.Process) *a.HTTP
 	return nil
 }
 
+func (c *MockClient) ListDatabases(context.Context, *mongodbatlas.Process) ([]*mongodbatlas.ProcessDatabase, *a.HTTPError) {
+	return c.givenDatabases, nil
+}
+
+func (c *MockClient) GetDatabaseMeasurements(_ context.Context, _ *measurer.Process, d *measurer.Database) error {
+	d.Measurements = c.givenDatabasesMeasurements
+	return nil
+}
+
+func (c *MockClient) GetDatabaseMeasurementsMetadata(_ context.Context, _ *measurer.Process, _ *measurer.Database) (map[model.MeasurementID]*model.MeasurementMetadata, error) {
+	return map[model.MeasurementID]*model.MeasurementMetadata{
+		model.NewMeasurementID("DATABASE_DATA_SIZE", "BYTES"): {
+			Name:  "DATABASE_DATA_SIZE",
+			Units: "BYTES",
+		},
+	}, nil
+}
+
+func (c *MockClient) GetSuggestedIndexes(context.Context, *measurer.Process) ([]*mongodbatlas.SuggestedIndex, *a.HTTPError) {
+	return c.givenSuggestedIndexes, nil
+}
+
+func (c *MockClient) GetSlowQueries(context.Context, *measurer.Process) ([]*mongodbatlas.SlowQuery, *a.HTTPError) {
+	return c.givenSlowQueries, nil
+}
+
+func (c *MockClient) AllowedMetricName(fqName string) bool {
+	return !c.givenDeniedMetricNames[fqName]
+}
+
+func (c *MockClient) MapMeasurement(name string) (config.MappedMeasurement, bool) {
+	return (&config.Config{Mappings: c.givenMappings}).MapMeasurement(name)
+}
+
+func (c *MockClient) UnitRule(model.UnitEnum) (config.UnitRule, bool) {
+	return config.UnitRule{}, false
+}
+
 func getGivenDiskMeasurements(value1 *float32) map[model.MeasurementID]*model.Measurement {
 	return map[model.MeasurementID]*model.Measurement{
 		"DISK_PARTITION_IOPS_READ_SCALAR_PER_SECOND": {
@@ -1,8 +1,12 @@
 package collector
 
 import (
+	"context"
 	"mongodbatlas_exporter/measurer"
+	m "mongodbatlas_exporter/model"
 	a "mongodbatlas_exporter/mongodbatlas"
+	"sync"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -14,22 +18,61 @@ import (
 const (
 	processesPrefix = "processes_stats"
 	disksPrefix     = "disks_stats"
+	databasesPrefix = "databases_stats"
+	advisorPrefix   = "perf_advisor"
 	infoHelp        = "Process info metric"
+
+	measurementsAgeHelp = "Age of the cached measurements of the process."
+
+	advisorSuggestedIndexesHelp    = "Number of indexes the Performance Advisor suggests for the namespace."
+	advisorSlowQueriesHelp         = "Number of slow queries the Performance Advisor logged for the namespace."
+	advisorSlowQueriesDurationHelp = "Total duration of the slow queries the Performance Advisor logged for the namespace."
 )
 
+// ProcessOptions configure how a process collector fetches its measurements.
+// The zero value fetches them on every scrape.
+type ProcessOptions struct {
+	//Cached makes Collect report the measurements of the last Poll instead of fetching them,
+	//so the number of API requests does not depend on the scrape interval.
+	Cached bool
+	//MaxStaleness is the age after which cached measurements are no longer reported, 0 reports them forever.
+	MaxStaleness time.Duration
+	//Pool runs the measurement requests, it is usually shared by all process collectors.
+	//If nil the requests of the collector are sent one after another.
+	Pool *FetchPool
+	//Timestamps reports every measurement with the timestamp of its Atlas datapoint, and only once.
+	Timestamps bool
+	//Aggregates reports the minimum, maximum and average of the datapoints of every measurement's period
+	//as _min, _max and _avg gauges, so spikes between scrapes are not lost.
+	Aggregates bool
+	//Counters additionally reports per second rates as _total counters integrating their datapoints.
+	Counters bool
+}
+
 // Process information struct
 type Process struct {
 	*basicCollector
 	info     prometheus.Gauge
+	options  ProcessOptions
 	measurer measurer.Process
+
+	suggestedIndexes, slowQueries, slowQueriesDuration *prometheus.Desc
+	measurementsAge                                    *prometheus.Desc
+
+	//mu guards the measurements of the measurers and the cache.
+	mu     sync.Mutex
+	cached *processMeasurements
 }
 
-func NewProcessCollector(logger log.Logger, client a.Client, p *mongodbatlas.Process) (*Process, error) {
+func NewProcessCollector(ctx context.Context, logger log.Logger, client a.Client, p *mongodbatlas.Process, options ProcessOptions) (*Process, error) {
+	if options.Pool == nil {
+		options.Pool = NewFetchPool(1, DefaultFetchTimeout)
+	}
 
 	processMeasurer := measurer.ProcessFromMongodbAtlasProcess(p)
 
 	//Spice the Measurer with the list of disks.
-	disks, httpErr := client.ListDisks(p)
+	disks, httpErr := client.ListDisks(ctx, p)
 
 	if httpErr != nil {
 		return nil, httpErr
@@ -42,7 +85,7 @@ func NewProcessCollector(logger log.Logger, client a.Client, p *mongodbatlas.Pro
 		processMeasurer.Disks = make([]*measurer.Disk, len(disks))
 		for i := range disks {
 			disk := measurer.DiskFromMongodbAtlasProcessDisk(p, disks[i])
-			diskMetadata, err := client.GetDiskMeasurementsMetadata(processMeasurer, disk)
+			diskMetadata, err := client.GetDiskMeasurementsMetadata(ctx, processMeasurer, disk)
 			if err != nil {
 				//TODO: Definitely needs to be a metric
 				level.Warn(logger).Log("msg", "could not get disk metadata", "disk", disk.PartitionName, "process", p.ID, "group", p.GroupID)
@@ -53,7 +96,7 @@ func NewProcessCollector(logger log.Logger, client a.Client, p *mongodbatlas.Pro
 			disk.Metadata = diskMetadata
 
 			//build list of prometheus metrics
-			err = measurer.BuildPromMetrics(disk, namespace, disksPrefix)
+			err = measurer.BuildPromMetrics(disk, namespace, disksPrefix, client)
 
 			if err != nil {
 				level.Warn(logger).Log("msg", "could not build disk prom metrics", "disk", disk.PartitionName, "process", p.ID, "group", p.GroupID)
@@ -65,32 +108,67 @@ func NewProcessCollector(logger log.Logger, client a.Client, p *mongodbatlas.Pro
 		}
 	}
 
+	//Databases are only listed if database measurements are enabled.
+	//Like disks they are skipped for MONGOS nodes as these do not store data.
+	if p.TypeName != a.TYPE_MONGOS {
+		databases, httpErr := client.ListDatabases(ctx, p)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		for i := range databases {
+			database := measurer.DatabaseFromMongodbAtlasProcessDatabase(p, databases[i])
+			databaseMetadata, err := client.GetDatabaseMeasurementsMetadata(ctx, processMeasurer, database)
+			if err != nil {
+				level.Warn(logger).Log("msg", "could not get database metadata", "database", database.DatabaseName, "process", p.ID, "group", p.GroupID, "err", err)
+				continue
+			}
+
+			database.Metadata = databaseMetadata
+
+			err = measurer.BuildPromMetrics(database, namespace, databasesPrefix, client)
+			if err != nil {
+				level.Warn(logger).Log("msg", "could not build database prom metrics", "database", database.DatabaseName, "process", p.ID, "group", p.GroupID, "err", err)
+				continue
+			}
+
+			processMeasurer.Databases = append(processMeasurer.Databases, database)
+		}
+	}
+
 	//get the metadata for the measurer.
 	//this should be part of the measurer.
-	httpErr = client.GetProcessMeasurementsMetadata(processMeasurer)
+	httpErr = client.GetProcessMeasurementsMetadata(ctx, processMeasurer)
 	if httpErr != nil {
 		return nil, httpErr
 	}
 
-	err := measurer.BuildPromMetrics(processMeasurer, namespace, processesPrefix)
+	err := measurer.BuildPromMetrics(processMeasurer, namespace, processesPrefix, client)
 
 	if err != nil {
 		return nil, err
 	}
 
-	for i := range processMeasurer.Disks {
-		err = measurer.BuildPromMetrics(processMeasurer.Disks[i], namespace, disksPrefix)
-
-		if err != nil {
-			return nil, err
+	countUnknownUnits(processMeasurer.PromMetrics())
+	for _, disk := range processMeasurer.Disks {
+		if disk != nil {
+			countUnknownUnits(disk.PromMetrics())
 		}
 	}
+	for _, database := range processMeasurer.Databases {
+		countUnknownUnits(database.PromMetrics())
+	}
 
 	basicCollector, err := newBasicCollector(logger, client, processMeasurer, processesPrefix)
 
 	if err != nil {
 		return nil, err
 	}
+	basicCollector.timestamps = options.Timestamps
+	basicCollector.aggregates = options.Aggregates
+	if options.Counters {
+		basicCollector.counters = newRateCounters()
+	}
 
 	process := &Process{
 		basicCollector: basicCollector,
@@ -100,58 +178,260 @@ func NewProcessCollector(logger log.Logger, client a.Client, p *mongodbatlas.Pro
 				Help:        infoHelp,
 				ConstLabels: processMeasurer.PromInfoConstLabels(),
 			}),
+		options:  options,
 		measurer: *processMeasurer,
+		measurementsAge: prometheus.NewDesc(prometheus.BuildFQName(namespace, processesPrefix, "measurements_age_seconds"),
+			measurementsAgeHelp, nil, processMeasurer.PromConstLabels()),
 	}
 
+	advisorLabels := (&measurer.PerformanceAdvisor{}).PromVariableLabelNames()
+	newAdvisorDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, advisorPrefix, name), help, advisorLabels, processMeasurer.PromConstLabels())
+	}
+	process.suggestedIndexes = newAdvisorDesc("suggested_indexes", advisorSuggestedIndexesHelp)
+	process.slowQueries = newAdvisorDesc("slow_queries", advisorSlowQueriesHelp)
+	process.slowQueriesDuration = newAdvisorDesc("slow_queries_duration_seconds", advisorSlowQueriesDurationHelp)
+
 	return process, nil
 }
 
+// Inherit takes over the state of the collector this one replaces after a metadata refresh,
+// the latest reported datapoints and the totals of the rate counters, so the refresh is no restart.
+// It must be called before the collector is registered.
+func (c *Process) Inherit(previous *Process) {
+	previous.emittedMu.Lock()
+	emitted := make(map[string]time.Time, len(previous.emitted))
+	for key, timestamp := range previous.emitted {
+		emitted[key] = timestamp
+	}
+	previous.emittedMu.Unlock()
+
+	c.emittedMu.Lock()
+	c.emitted = emitted
+	c.emittedMu.Unlock()
+
+	//the series keys of unchanged metrics are the same, so the totals continue.
+	if c.counters != nil && previous.counters != nil {
+		c.counters = previous.counters
+	}
+}
+
+// processMeasurements are the measurements of a process, its disks and databases
+// and its Performance Advisor results fetched at one point in time.
+type processMeasurements struct {
+	fetched time.Time
+	process map[m.MeasurementID]*m.Measurement
+	//disks and databases are in the order of the measurer's Disks and Databases,
+	//entries that could not be fetched are nil.
+	disks, databases []map[m.MeasurementID]*m.Measurement
+	//advisor is nil if the Performance Advisor results could not be fetched.
+	advisor *measurer.PerformanceAdvisor
+}
+
+// Poll fetches the measurements and keeps them for Collect.
+// It is only called by the registerer if ProcessOptions.Cached is set.
+func (c *Process) Poll() {
+	ctx, cancel := c.options.Pool.fetchContext()
+	defer cancel()
+	measurements := c.fetch(ctx)
+
+	c.mu.Lock()
+	c.cached = measurements
+	c.mu.Unlock()
+}
+
+// Collect implements prometheus.Collector, the measurements are fetched with the timeout of the pool.
 func (c *Process) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := c.options.Pool.fetchContext()
+	defer cancel()
+	c.CollectContext(ctx, ch)
+}
+
+// CollectContext implements ContextCollector, the measurements are fetched with the context of the scrape.
+func (c *Process) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	defer c.scrapeMetrics.collect(ch)
+
+	var measurements *processMeasurements
+	if c.options.Cached {
+		measurements = c.cachedMeasurements(ch)
+	} else {
+		measurements = c.fetch(ctx)
+	}
+
+	c.info.Set(1)
+	ch <- c.info
+
+	if measurements != nil {
+		c.collectMeasurements(measurements, ch)
+	}
+}
+
+// cachedMeasurements returns the measurements of the last Poll and reports their age.
+// Measurements older than ProcessOptions.MaxStaleness are not returned.
+func (c *Process) cachedMeasurements(ch chan<- prometheus.Metric) *processMeasurements {
+	c.mu.Lock()
+	measurements := c.cached
+	c.mu.Unlock()
+
+	if measurements == nil {
+		return nil
+	}
+
+	age := time.Since(measurements.fetched)
+	ch <- prometheus.MustNewConstMetric(c.measurementsAge, prometheus.GaugeValue, age.Seconds())
+
+	if c.options.MaxStaleness > 0 && age > c.options.MaxStaleness {
+		level.Debug(c.logger).Log("msg", "skipping stale measurements", "host", c.measurer.ID, "age", age)
+		return nil
+	}
+	return measurements
+}
+
+// fetch queries the Atlas API for the measurements of the process, its disks and databases in parallel on the pool.
+// The measurers are only read, so a poll can run while Collect reports the previous measurements.
+func (c *Process) fetch(ctx context.Context) *processMeasurements {
 	c.totalScrapes.Inc()
-	defer func() {
-		ch <- c.up
-		ch <- c.totalScrapes
-		ch <- c.scrapeFailures
-	}()
 
-	processMeasurements, err := c.client.GetProcessMeasurements(c.measurer)
+	c.mu.Lock()
+	process := c.measurer
+	disks := make([]measurer.Disk, len(c.measurer.Disks))
+	for i, disk := range c.measurer.Disks {
+		if disk != nil {
+			disks[i] = *disk
+		}
+	}
+	databases := make([]measurer.Database, len(c.measurer.Databases))
+	for i, database := range c.measurer.Databases {
+		databases[i] = *database
+	}
+	c.mu.Unlock()
+
+	measurements := &processMeasurements{
+		fetched:   time.Now(),
+		disks:     make([]map[m.MeasurementID]*m.Measurement, len(disks)),
+		databases: make([]map[m.MeasurementID]*m.Measurement, len(databases)),
+	}
+
+	//every task writes its own fields of measurements only.
+	tasks := []func(context.Context){func(ctx context.Context) {
+		var err error
+		measurements.process, err = c.client.GetProcessMeasurements(ctx, process)
+		if err != nil {
+			level.Debug(c.logger).Log("msg", "scrape failure", "host", c.measurer.ID, "err", err)
+			c.scrapeFailures.Inc()
+			c.up.Set(0)
+		} else {
+			c.up.Set(1)
+		}
+	}}
+
+	for i := range disks {
+		if c.measurer.Disks[i] == nil {
+			continue
+		}
+		i := i
+		tasks = append(tasks, func(ctx context.Context) {
+			if err := c.client.GetDiskMeasurements(ctx, &process, &disks[i]); err != nil {
+				level.Debug(c.logger).Log("msg", "skipping disk", "disk", disks[i].PartitionName, "host", disks[i].ID,
+					"err", err)
+				return
+			}
+			measurements.disks[i] = disks[i].Measurements
+		})
+	}
+
+	for i := range databases {
+		i := i
+		tasks = append(tasks, func(ctx context.Context) {
+			if err := c.client.GetDatabaseMeasurements(ctx, &process, &databases[i]); err != nil {
+				level.Debug(c.logger).Log("msg", "skipping database", "database", databases[i].DatabaseName, "host", databases[i].ID,
+					"err", err)
+				return
+			}
+			measurements.databases[i] = databases[i].Measurements
+		})
+	}
+
+	//like disks and databases MONGOS nodes have no Performance Advisor results.
+	if process.TypeName != a.TYPE_MONGOS {
+		tasks = append(tasks, func(ctx context.Context) {
+			measurements.advisor = c.fetchPerformanceAdvisor(ctx, &process)
+		})
+	}
+
+	c.options.Pool.run(ctx, tasks)
+	return measurements
+}
 
+//fetchPerformanceAdvisor returns the suggested indexes and slow queries of the process.
+//The result is empty if the Performance Advisor is disabled.
+func (c *Process) fetchPerformanceAdvisor(ctx context.Context, process *measurer.Process) *measurer.PerformanceAdvisor {
+	indexes, err := c.client.GetSuggestedIndexes(ctx, process)
 	if err != nil {
-		x := err.Error()
-		level.Debug(c.logger).Log("msg", "scrape failure", "err", err, "x", x)
-		c.scrapeFailures.Inc()
-		c.up.Set(0)
+		level.Debug(c.logger).Log("msg", "skipping suggested indexes", "host", process.ID, "err", err)
+		return nil
 	}
-	c.up.Set(1)
+	queries, err := c.client.GetSlowQueries(ctx, process)
+	if err != nil {
+		level.Debug(c.logger).Log("msg", "skipping slow queries", "host", process.ID, "err", err)
+		return nil
+	}
+	return measurer.PerformanceAdvisorFromMongodbAtlas(indexes, queries)
+}
+
+// collectMeasurements hands the measurements to the measurers and reports them.
+// Disks and databases whose measurements could not be fetched keep their previous ones.
+func (c *Process) collectMeasurements(measurements *processMeasurements, ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	c.measurer.Measurements = processMeasurements
+	c.measurer.Measurements = measurements.process
 
 	for _, metric := range c.measurer.PromMetrics() {
-		err = c.report(&c.measurer, metric, ch)
+		err := c.report(&c.measurer, metric, ch)
 		if err != nil {
 			level.Debug(c.logger).Log("msg", "skipping metric", "metric", metric.Desc,
 				"err", err)
 		}
 	}
 
-	c.info.Set(1)
-	ch <- c.info
-
-	for _, disk := range c.measurer.Disks {
-		err := c.client.GetDiskMeasurements(&c.measurer, disk)
-
-		if err != nil {
-			level.Debug(c.logger).Log("msg", "skipping disk", "disk", disk.PartitionName, "host", disk.ID,
-				"err", err)
+	for i, disk := range c.measurer.Disks {
+		if disk == nil {
+			continue
+		}
+		if measurements.disks[i] != nil {
+			disk.Measurements = measurements.disks[i]
 		}
 		for _, metric := range disk.PromMetrics() {
-			err = c.report(disk, metric, ch)
+			err := c.report(disk, metric, ch)
 			if err != nil {
 				level.Debug(c.logger).Log("msg", "skipping metric", "metric", metric.Desc,
 					"err", err)
 			}
 		}
 	}
+	for i, database := range c.measurer.Databases {
+		if measurements.databases[i] != nil {
+			database.Measurements = measurements.databases[i]
+		}
+		for _, metric := range database.PromMetrics() {
+			err := c.report(database, metric, ch)
+			if err != nil {
+				level.Debug(c.logger).Log("msg", "skipping metric", "metric", metric.Desc,
+					"err", err)
+			}
+		}
+	}
+
+	if advisor := measurements.advisor; advisor != nil {
+		for ns, count := range advisor.SuggestedIndexes {
+			ch <- prometheus.MustNewConstMetric(c.suggestedIndexes, prometheus.GaugeValue, float64(count), ns)
+		}
+		for ns, count := range advisor.SlowQueries {
+			ch <- prometheus.MustNewConstMetric(c.slowQueries, prometheus.GaugeValue, float64(count), ns)
+			ch <- prometheus.MustNewConstMetric(c.slowQueriesDuration, prometheus.GaugeValue, advisor.SlowQueriesDurationSeconds[ns], ns)
+		}
+	}
 }
 
 // Describe implements prometheus.Collector.
@@ -160,9 +440,16 @@ func (c *Process) Describe(ch chan<- *prometheus.Desc) {
 
 	//add the disk metrics
 	for _, d := range c.measurer.Disks {
-		for _, metric := range d.PromMetrics() {
-			ch <- metric.Desc
-		}
+		c.describeMetrics(d.PromMetrics(), ch)
+	}
+
+	//add the database metrics
+	for _, d := range c.measurer.Databases {
+		c.describeMetrics(d.PromMetrics(), ch)
 	}
 	c.info.Describe(ch)
+	ch <- c.suggestedIndexes
+	ch <- c.slowQueries
+	ch <- c.slowQueriesDuration
+	ch <- c.measurementsAge
 }
@@ -1,19 +1,28 @@
 package collector
 
 import (
+	"context"
 	"mongodbatlas_exporter/measurer"
 	m "mongodbatlas_exporter/model"
 	a "mongodbatlas_exporter/mongodbatlas"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/atlas/mongodbatlas"
 )
 
-func (c *MockClient) ListProcesses() ([]*mongodbatlas.Process, *a.HTTPError) {
+func (c *MockClient) ListProjects(context.Context) ([]string, *a.HTTPError) {
+	return nil, nil
+}
+
+func (c *MockClient) ListProcesses(context.Context, string) ([]*mongodbatlas.Process, *a.HTTPError) {
 	return nil, nil
 }
 
@@ -25,14 +34,19 @@ func (c *MockClient) ListProcesses() ([]*mongodbatlas.Process, *a.HTTPError) {
 var processExpectedDescs = append(commontestExpectedDescs,
 	//Process Specific Metric Descriptions
 	[]string{prometheus.BuildFQName(namespace, processesPrefix, "info"), infoHelp},
-	[]string{prometheus.BuildFQName(namespace, processesPrefix, "query_executor_scanned_ratio"), "Original measurements.name: 'QUERY_EXECUTOR_SCANNED'. " + measurer.DEFAULT_HELP},
-	[]string{prometheus.BuildFQName(namespace, processesPrefix, "tickets_available_reads"), "Original measurements.name: 'TICKETS_AVAILABLE_READS'. " + measurer.DEFAULT_HELP},
+	[]string{prometheus.BuildFQName(namespace, processesPrefix, "measurements_age_seconds"), measurementsAgeHelp},
+	[]string{prometheus.BuildFQName(namespace, processesPrefix, "query_executor_scanned_ratio"), "Original measurements.name: 'QUERY_EXECUTOR_SCANNED'. Index items scanned by queries per second."},
+	[]string{prometheus.BuildFQName(namespace, processesPrefix, "tickets_available_reads"), "Original measurements.name: 'TICKETS_AVAILABLE_READS'. Available WiredTiger read tickets, operations wait once none are left."},
+	//Performance Advisor Metric Descriptions
+	[]string{prometheus.BuildFQName(namespace, advisorPrefix, "suggested_indexes"), advisorSuggestedIndexesHelp, "namespace"},
+	[]string{prometheus.BuildFQName(namespace, advisorPrefix, "slow_queries"), advisorSlowQueriesHelp, "namespace"},
+	[]string{prometheus.BuildFQName(namespace, advisorPrefix, "slow_queries_duration_seconds"), advisorSlowQueriesDurationHelp, "namespace"},
 )
 
 var diskExpectedDescs = [][]string{
 	//This disk metric should be attached to the sub-resource for disks on the process measurer
-	{prometheus.BuildFQName(namespace, disksPrefix, "disk_partition_iops_read_ratio"), "Original measurements.name: 'DISK_PARTITION_IOPS_READ'. " + measurer.DEFAULT_HELP},
-	{prometheus.BuildFQName(namespace, disksPrefix, "disk_partition_space_used_bytes"), "Original measurements.name: 'DISK_PARTITION_SPACE_USED'. " + measurer.DEFAULT_HELP},
+	{prometheus.BuildFQName(namespace, disksPrefix, "disk_partition_iops_read_ratio"), "Original measurements.name: 'DISK_PARTITION_IOPS_READ'. Read operations on the partition per second."},
+	{prometheus.BuildFQName(namespace, disksPrefix, "disk_partition_space_used_bytes"), "Original measurements.name: 'DISK_PARTITION_SPACE_USED'. Used space of the partition."},
 }
 
 var testAtlasProcess = mongodbatlas.Process{
@@ -66,7 +80,7 @@ func TestProcessDescribe(t *testing.T) {
 	processExpectedDescs := getExpectedDescs(processMeasurer, append(commontestExpectedDescs, processExpectedDescs...))
 
 	//make descriptions for disk metrics
-	disks, httpErr := mock.ListDisks(&process)
+	disks, httpErr := mock.ListDisks(context.Background(), &process)
 
 	assert.Nil(t, httpErr)
 
@@ -74,7 +88,7 @@ func TestProcessDescribe(t *testing.T) {
 
 	allExpectedDecs := append(processExpectedDescs, getExpectedDescs(diskMeasurer, diskExpectedDescs)...)
 
-	processCollector, err := NewProcessCollector(logger, mock, &process)
+	processCollector, err := NewProcessCollector(context.Background(), logger, mock, &process, ProcessOptions{})
 
 	assert.NoError(t, err)
 	assert.NotNil(t, processCollector)
@@ -90,7 +104,7 @@ func TestProcessesCollector(t *testing.T) {
 	mock.givenDisksMeasurements = getGivenDiskMeasurements(&value)
 	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 
-	processCollector, err := NewProcessCollector(logger, mock, &testAtlasProcess)
+	processCollector, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{})
 	assert.NotNil(processCollector)
 	assert.NoError(err)
 
@@ -145,7 +159,7 @@ func getExpectedProcessesMetrics(value float64) []prometheus.Metric {
 	processInputs := []metricInput{
 		{
 			fqName: prometheus.BuildFQName(namespace, processesPrefix, "query_executor_scanned_ratio"),
-			help:   "Original measurements.name: 'QUERY_EXECUTOR_SCANNED'. " + measurer.DEFAULT_HELP,
+			help:   "Original measurements.name: 'QUERY_EXECUTOR_SCANNED'. Index items scanned by queries per second.",
 			value:  value,
 		},
 		{
@@ -182,7 +196,7 @@ func getExpectedProcessesMetrics(value float64) []prometheus.Metric {
 	diskInputs := []metricInput{
 		{
 			fqName: prometheus.BuildFQName(namespace, disksPrefix, "disk_partition_iops_read_ratio"),
-			help:   "Original measurements.name: 'DISK_PARTITION_IOPS_READ'. " + measurer.DEFAULT_HELP,
+			help:   "Original measurements.name: 'DISK_PARTITION_IOPS_READ'. Read operations on the partition per second.",
 			value:  value,
 		},
 	}
@@ -226,3 +240,269 @@ func getExpectedProcessesMetrics(value float64) []prometheus.Metric {
 	}
 	return expectedMetrics
 }
+
+//TestProcessesCollector_databases checks that the measurements of every listed
+//database are reported with the database_name label.
+func TestProcessesCollector_databases(t *testing.T) {
+	assert := assert.New(t)
+	value := float32(2048)
+	mock := &MockClient{
+		givenDatabases: []*mongodbatlas.ProcessDatabase{
+			{DatabaseName: "tenant_a"},
+			{DatabaseName: "tenant_b"},
+		},
+		givenDatabasesMeasurements: map[m.MeasurementID]*m.Measurement{
+			"DATABASE_DATA_SIZE_BYTES": {
+				DataPoints: []*mongodbatlas.DataPoints{
+					{
+						Timestamp: "2021-03-07T15:47:13Z",
+						Value:     &value,
+					},
+				},
+				Units: m.BYTES,
+			},
+		},
+	}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	processCollector, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{})
+	assert.NoError(err)
+	assert.Len(processCollector.measurer.Databases, 2)
+
+	metricsCh := make(chan prometheus.Metric, 99)
+	defer close(metricsCh)
+	processCollector.Collect(metricsCh)
+
+	databaseNames := map[string]bool{}
+	for len(metricsCh) > 0 {
+		metric := &dto.Metric{}
+		collected := <-metricsCh
+		assert.NoError(collected.Write(metric))
+		if !strings.Contains(collected.Desc().String(), prometheus.BuildFQName(namespace, databasesPrefix, "database_data_size_bytes")) {
+			continue
+		}
+		assert.Equal(float64(value), metric.GetGauge().GetValue())
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == "database_name" {
+				databaseNames[label.GetValue()] = true
+			}
+		}
+	}
+	assert.Equal(map[string]bool{"tenant_a": true, "tenant_b": true}, databaseNames)
+}
+
+func TestProcessesCollector_performanceAdvisor(t *testing.T) {
+	mock := &MockClient{
+		givenSuggestedIndexes: []*mongodbatlas.SuggestedIndex{
+			{Namespace: "shop.orders"},
+		},
+		givenSlowQueries: []*mongodbatlas.SlowQuery{
+			{Namespace: "shop.orders", Line: `{"attr":{"ns":"shop.orders","durationMillis":1500}}`},
+			{Namespace: "shop.users", Line: `{"attr":{"ns":"shop.users","durationMillis":500}}`},
+		},
+	}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	processCollector, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{})
+	assert.NoError(t, err)
+
+	expected := `
+# HELP mongodbatlas_perf_advisor_slow_queries Number of slow queries the Performance Advisor logged for the namespace.
+# TYPE mongodbatlas_perf_advisor_slow_queries gauge
+mongodbatlas_perf_advisor_slow_queries{namespace="shop.orders",project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 1
+mongodbatlas_perf_advisor_slow_queries{namespace="shop.users",project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 1
+# HELP mongodbatlas_perf_advisor_slow_queries_duration_seconds Total duration of the slow queries the Performance Advisor logged for the namespace.
+# TYPE mongodbatlas_perf_advisor_slow_queries_duration_seconds gauge
+mongodbatlas_perf_advisor_slow_queries_duration_seconds{namespace="shop.orders",project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 1.5
+mongodbatlas_perf_advisor_slow_queries_duration_seconds{namespace="shop.users",project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 0.5
+# HELP mongodbatlas_perf_advisor_suggested_indexes Number of indexes the Performance Advisor suggests for the namespace.
+# TYPE mongodbatlas_perf_advisor_suggested_indexes gauge
+mongodbatlas_perf_advisor_suggested_indexes{namespace="shop.orders",project_id="testProjectID",rs_name="testReplicaSet",user_alias="cluster-host:27017:0"} 1
+`
+	err = testutil.CollectAndCompare(processCollector, strings.NewReader(expected),
+		"mongodbatlas_perf_advisor_slow_queries", "mongodbatlas_perf_advisor_slow_queries_duration_seconds",
+		"mongodbatlas_perf_advisor_suggested_indexes")
+	assert.NoError(t, err)
+}
+
+//TestProcessesCollector_cached checks that a cached collector only queries Atlas when polled
+//and stops reporting measurements once they are stale.
+func TestProcessesCollector_cached(t *testing.T) {
+	value := float32(5)
+	mock := &MockClient{givenProcessesMeasurements: getGivenProcessesMeasurements(&value)}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	processCollector, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{Cached: true, MaxStaleness: time.Hour})
+	assert.NoError(t, err)
+
+	//nothing was polled yet
+	assert.Equal(t, 0, testutil.CollectAndCount(processCollector, "mongodbatlas_processes_stats_query_executor_scanned_ratio"))
+
+	processCollector.Poll()
+	mock.givenProcessesMeasurements = nil
+	assert.Equal(t, 1, testutil.CollectAndCount(processCollector, "mongodbatlas_processes_stats_query_executor_scanned_ratio"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(processCollector.totalScrapes))
+	assert.Equal(t, 1, testutil.CollectAndCount(processCollector, "mongodbatlas_processes_stats_measurements_age_seconds"))
+
+	processCollector.options.MaxStaleness = time.Nanosecond
+	assert.Equal(t, 0, testutil.CollectAndCount(processCollector, "mongodbatlas_processes_stats_query_executor_scanned_ratio"))
+}
+
+//TestProcessesCollector_timestamps checks that measurements carry the timestamp of their datapoint
+//and that a datapoint is not reported a second time.
+func TestProcessesCollector_timestamps(t *testing.T) {
+	assert := assert.New(t)
+	value := float32(5)
+	mock := &MockClient{givenProcessesMeasurements: getGivenProcessesMeasurements(&value)}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	processCollector, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{Timestamps: true})
+	assert.NoError(err)
+
+	metric := collectMetric(processCollector, "mongodbatlas_processes_stats_query_executor_scanned_ratio")
+	assert.NotNil(metric)
+	assert.Equal(time.Date(2021, 3, 7, 15, 47, 13, 0, time.UTC).UnixNano()/int64(time.Millisecond), metric.GetTimestampMs())
+	assert.Equal(float64(value), metric.GetGauge().GetValue())
+
+	assert.Nil(collectMetric(processCollector, "mongodbatlas_processes_stats_query_executor_scanned_ratio"))
+}
+
+//TestProcessesCollector_inherit checks that a collector replacing another one after a metadata refresh
+//does not report the datapoints the previous one already reported.
+func TestProcessesCollector_inherit(t *testing.T) {
+	assert := assert.New(t)
+	value := float32(5)
+	mock := &MockClient{givenProcessesMeasurements: getGivenProcessesMeasurements(&value)}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	previous, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{Timestamps: true, Counters: true})
+	assert.NoError(err)
+	assert.NotNil(collectMetric(previous, "mongodbatlas_processes_stats_query_executor_scanned_ratio"))
+
+	refreshed, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{Timestamps: true, Counters: true})
+	assert.NoError(err)
+	refreshed.Inherit(previous)
+
+	assert.Nil(collectMetric(refreshed, "mongodbatlas_processes_stats_query_executor_scanned_ratio"))
+	assert.Same(previous.counters, refreshed.counters)
+}
+
+//TestProcessesCollector_aggregates checks that the datapoints of the period are aggregated
+//in addition to the latest value.
+func TestProcessesCollector_aggregates(t *testing.T) {
+	assert := assert.New(t)
+	value1, value2, value3 := float32(4), float32(9), float32(2)
+	mock := &MockClient{givenProcessesMeasurements: map[m.MeasurementID]*m.Measurement{
+		"QUERY_EXECUTOR_SCANNED_SCALAR_PER_SECOND": {
+			DataPoints: []*mongodbatlas.DataPoints{
+				{Timestamp: "2021-03-07T15:45:13Z", Value: &value1},
+				{Timestamp: "2021-03-07T15:46:13Z", Value: &value2},
+				{Timestamp: "2021-03-07T15:47:13Z", Value: &value3},
+			},
+			Units: m.SCALAR_PER_SECOND,
+		},
+	}}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	processCollector, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{Aggregates: true})
+	assert.NoError(err)
+
+	expected := map[string]float64{
+		"mongodbatlas_processes_stats_query_executor_scanned_ratio":     2,
+		"mongodbatlas_processes_stats_query_executor_scanned_ratio_min": 2,
+		"mongodbatlas_processes_stats_query_executor_scanned_ratio_max": 9,
+		"mongodbatlas_processes_stats_query_executor_scanned_ratio_avg": 5,
+	}
+	for fqName, value := range expected {
+		metric := collectMetric(processCollector, fqName)
+		if assert.NotNil(metric, fqName) {
+			assert.Equal(value, metric.GetGauge().GetValue(), fqName)
+		}
+	}
+
+	//measurements without datapoints have no aggregates.
+	assert.Nil(collectMetric(processCollector, "mongodbatlas_processes_stats_tickets_available_reads_min"))
+
+	//the aggregates are described, so a pedantic registry accepts them.
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(registry.Register(processCollector))
+	_, err = registry.Gather()
+	assert.NoError(err)
+}
+
+//TestProcessesCollector_counters checks that rates are integrated into counters across scrapes.
+func TestProcessesCollector_counters(t *testing.T) {
+	assert := assert.New(t)
+	value1, value2 := float32(2), float32(3)
+	mock := &MockClient{givenProcessesMeasurements: map[m.MeasurementID]*m.Measurement{
+		"QUERY_EXECUTOR_SCANNED_SCALAR_PER_SECOND": {
+			DataPoints: []*mongodbatlas.DataPoints{
+				{Timestamp: "2021-03-07T15:46:13Z", Value: &value1},
+				{Timestamp: "2021-03-07T15:47:13Z", Value: &value2},
+			},
+			Units: m.SCALAR_PER_SECOND,
+		},
+	}}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	processCollector, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{Counters: true})
+	assert.NoError(err)
+
+	metric := collectMetric(processCollector, "mongodbatlas_processes_stats_query_executor_scanned_total")
+	if assert.NotNil(metric) {
+		assert.Equal(float64(300), metric.GetCounter().GetValue())
+	}
+
+	//the next period overlaps with the previous one.
+	mock.givenProcessesMeasurements["QUERY_EXECUTOR_SCANNED_SCALAR_PER_SECOND"].DataPoints = []*mongodbatlas.DataPoints{
+		{Timestamp: "2021-03-07T15:47:13Z", Value: &value2},
+		{Timestamp: "2021-03-07T15:48:13Z", Value: &value1},
+	}
+	metric = collectMetric(processCollector, "mongodbatlas_processes_stats_query_executor_scanned_total")
+	if assert.NotNil(metric) {
+		assert.Equal(float64(420), metric.GetCounter().GetValue())
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(registry.Register(processCollector))
+	_, err = registry.Gather()
+	assert.NoError(err)
+}
+
+//TestProcessesCollector_metricNameFilter checks that metrics rejected by the name filter are not described.
+func TestProcessesCollector_metricNameFilter(t *testing.T) {
+	assert := assert.New(t)
+	denied := prometheus.BuildFQName(namespace, disksPrefix, "disk_partition_iops_read_ratio")
+	mock := &MockClient{givenDeniedMetricNames: map[string]bool{denied: true}}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+	processCollector, err := NewProcessCollector(context.Background(), logger, mock, &testAtlasProcess, ProcessOptions{})
+	assert.NoError(err)
+
+	ch := make(chan *prometheus.Desc, 99)
+	processCollector.Describe(ch)
+	close(ch)
+	var described []string
+	for desc := range ch {
+		described = append(described, desc.String())
+	}
+	assert.NotContains(strings.Join(described, "\n"), `"`+denied+`"`)
+	assert.Contains(strings.Join(described, "\n"), `"`+prometheus.BuildFQName(namespace, disksPrefix, "disk_partition_space_used_bytes")+`"`)
+}
+
+//collectMetric returns the first metric the collector reports under fqName.
+func collectMetric(collector prometheus.Collector, fqName string) *dto.Metric {
+	ch := make(chan prometheus.Metric, 99)
+	collector.Collect(ch)
+	close(ch)
+
+	for metric := range ch {
+		if !strings.Contains(metric.Desc().String(), `"`+fqName+`"`) {
+			continue
+		}
+		result := &dto.Metric{}
+		metric.Write(result)
+		return result
+	}
+	return nil
+}
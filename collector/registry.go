@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry is a prometheus.Registerer whose collectors are gathered with the context of every scrape.
+// Concurrent scrapes, e.g. by a HA pair of Prometheus servers, each cancel only their own requests.
+type Registry struct {
+	//registry checks the collectors on registration, the ones of the scrapes rely on it.
+	registry *prometheus.Registry
+
+	mu         sync.RWMutex
+	collectors map[prometheus.Collector]struct{}
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		registry:   prometheus.NewRegistry(),
+		collectors: make(map[prometheus.Collector]struct{}),
+	}
+}
+
+// Register implements prometheus.Registerer.
+func (r *Registry) Register(c prometheus.Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.registry.Register(c); err != nil {
+		return err
+	}
+	r.collectors[c] = struct{}{}
+	return nil
+}
+
+// MustRegister implements prometheus.Registerer.
+func (r *Registry) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister implements prometheus.Registerer.
+// Unlike prometheus.Registry it only unregisters c itself, not another collector with the same descriptions.
+func (r *Registry) Unregister(c prometheus.Collector) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.collectors[c]; !ok {
+		return false
+	}
+	delete(r.collectors, c)
+	return r.registry.Unregister(c)
+}
+
+// Gatherer returns the gatherer of a scrape, which collects the registered ContextCollectors with ctx.
+func (r *Registry) Gatherer(ctx context.Context) prometheus.Gatherer {
+	scrape := prometheus.NewRegistry()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for c := range r.collectors {
+		//collectors without descriptions are registered unchecked, which never fails.
+		scrape.MustRegister(scrapeCollector{ctx: ctx, collector: c})
+	}
+	return scrape
+}
+
+// HandlerFor serves the metrics of the registry together with the ones of gatherer, see promhttp.HandlerFor.
+// The registry is gathered with the context of the request, see InstrumentScrapeTimeout for its deadline.
+func (r *Registry) HandlerFor(gatherer prometheus.Gatherer, opts promhttp.HandlerOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gatherers := prometheus.Gatherers{gatherer, r.Gatherer(req.Context())}
+		promhttp.HandlerFor(gatherers, opts).ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// contextValueCollector reports the value of its key in the context it is collected with.
+type contextValueCollector struct {
+	desc *prometheus.Desc
+}
+
+type contextKey struct{}
+
+func (c *contextValueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *contextValueCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectContext(context.Background(), ch)
+}
+
+func (c *contextValueCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	value, _ := ctx.Value(contextKey{}).(float64)
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, value)
+}
+
+// TestRegistry checks that every scrape collects with its own context.
+func TestRegistry(t *testing.T) {
+	assert := assert.New(t)
+	registry := NewRegistry()
+	assert.NoError(registry.Register(&contextValueCollector{desc: prometheus.NewDesc("context_value", "Value in the context.", nil, nil)}))
+	//collectors without context are collected as usual.
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "plain", Help: "Plain gauge."}))
+
+	//gatherers of concurrent scrapes.
+	scrapes := []prometheus.Gatherer{
+		registry.Gatherer(context.WithValue(context.Background(), contextKey{}, 1.0)),
+		registry.Gatherer(context.WithValue(context.Background(), contextKey{}, 2.0)),
+	}
+	for i, scrape := range scrapes {
+		families, err := scrape.Gather()
+		assert.NoError(err)
+		assert.Len(families, 2)
+		for _, family := range families {
+			if family.GetName() == "context_value" {
+				assert.Equal(float64(i+1), family.Metric[0].GetGauge().GetValue())
+			}
+		}
+	}
+}
+
+func TestRegistry_register(t *testing.T) {
+	assert := assert.New(t)
+	registry := NewRegistry()
+	desc := prometheus.NewDesc("context_value", "Value in the context.", nil, nil)
+	registered, duplicate := &contextValueCollector{desc: desc}, &contextValueCollector{desc: desc}
+
+	assert.NoError(registry.Register(registered))
+	assert.Error(registry.Register(duplicate))
+	assert.Panics(func() { registry.MustRegister(duplicate) })
+
+	//only the registered collector itself is unregistered.
+	assert.False(registry.Unregister(duplicate))
+	assert.True(registry.Unregister(registered))
+	families, err := registry.Gatherer(context.Background()).Gather()
+	assert.NoError(err)
+	assert.Empty(families)
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// InstrumentScrapeTimeout sets the deadline of every scrape's request context to the timeout Prometheus sends
+// in the X-Prometheus-Scrape-Timeout-Seconds header, so collectors stop waiting for Atlas before Prometheus
+// gives up on the scrape. offset is subtracted from the timeout to leave time for writing the response.
+// Scrapes without the header have defaultTimeout as deadline.
+func InstrumentScrapeTimeout(offset, defaultTimeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := defaultTimeout
+		if seconds, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64); err == nil && seconds > 0 {
+			if headerTimeout := time.Duration(seconds*float64(time.Second)) - offset; headerTimeout > 0 {
+				timeout = headerTimeout
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ContextCollector is a collector whose API requests can be bound to the context of a scrape,
+// which prometheus.Collector.Collect does not receive. A Registry collects it with CollectContext,
+// so the requests of a scrape are cancelled when Prometheus gives up on it or closes the connection.
+type ContextCollector interface {
+	prometheus.Collector
+	CollectContext(ctx context.Context, ch chan<- prometheus.Metric)
+}
+
+//scrapeCollector collects a collector with the context of a scrape.
+//It describes no metrics, so registering it with the registry of the scrape skips the checks
+//the collector already passed when it was registered with the Registry.
+type scrapeCollector struct {
+	ctx       context.Context
+	collector prometheus.Collector
+}
+
+func (c scrapeCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	if collector, ok := c.collector.(ContextCollector); ok {
+		collector.CollectContext(c.ctx, ch)
+		return
+	}
+	c.collector.Collect(ch)
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scrapeDeadline returns the deadline of the request context of a scrape with the timeout header.
+func scrapeDeadline(t *testing.T, header string) time.Time {
+	var deadline time.Time
+	var ok bool
+	handler := InstrumentScrapeTimeout(500*time.Millisecond, time.Minute, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if header != "" {
+		req.Header.Set(scrapeTimeoutHeader, header)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.True(t, ok, "scrape without deadline")
+	return deadline
+}
+
+func TestInstrumentScrapeTimeout(t *testing.T) {
+	assert.WithinDuration(t, time.Now().Add(14500*time.Millisecond), scrapeDeadline(t, "15"), time.Second)
+}
+
+// TestInstrumentScrapeTimeout_noHeader checks that scrapes without a usable timeout header have the default deadline.
+func TestInstrumentScrapeTimeout_noHeader(t *testing.T) {
+	assert.WithinDuration(t, time.Now().Add(time.Minute), scrapeDeadline(t, ""), time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), scrapeDeadline(t, "invalid"), time.Second)
+	//a timeout shorter than the offset leaves no time for the scrape.
+	assert.WithinDuration(t, time.Now().Add(time.Minute), scrapeDeadline(t, "0.1"), time.Second)
+}
+
+// TestInstrumentScrapeTimeout_cancel checks that the context of a scrape is done when the client goes away.
+func TestInstrumentScrapeTimeout_cancel(t *testing.T) {
+	done := make(chan struct{})
+	handler := InstrumentScrapeTimeout(0, time.Minute, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(done)
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil).WithContext(ctx)
+	go handler.ServeHTTP(httptest.NewRecorder(), req)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("context not done after the client went away")
+	}
+}
@@ -0,0 +1,79 @@
+package transformer
+
+import (
+	"errors"
+	"math"
+	m "mongodbatlas_exporter/model"
+	"strings"
+	"time"
+)
+
+//counterTransformationRules are the rates that can be integrated into counters.
+//valueMultiplier converts a rate into the counter's unit per second.
+var counterTransformationRules = map[m.UnitEnum]unitTransformationRules{
+	m.SCALAR_PER_SECOND:    {valueMultiplier: 1, nameSuffix: "_total"},
+	m.BYTES_PER_SECOND:     {valueMultiplier: 1, nameSuffix: "_bytes_total"},
+	m.MEGABYTES_PER_SECOND: {valueMultiplier: math.Pow(1024, 2), nameSuffix: "_bytes_total"},
+	m.GIGABYTES_PER_HOUR:   {valueMultiplier: math.Pow(1024, 3) / 3600, nameSuffix: "_bytes_total"},
+}
+
+// ErrUnknownGranularity is returned for rates with less than two datapoints,
+// as the interval a datapoint covers can not be told from a single one.
+var ErrUnknownGranularity = errors.New("the granularity of a single datapoint is unknown")
+
+// TransformCounterName returns the name of the counter integrating a rate measurement.
+// ok is false if the measurement is not a rate.
+func TransformCounterName(measurement *m.MeasurementMetadata) (name string, ok bool) {
+	rule, ok := counterTransformationRules[measurement.Units]
+	if !ok || measurement.Name == "" {
+		return "", false
+	}
+	return strings.ToLower(measurement.Name) + rule.nameSuffix, true
+}
+
+// Increment is the increase of a counter over the interval of a single datapoint.
+type Increment struct {
+	Timestamp time.Time
+	Value     float64
+	//Valid is false for datapoints without a value, e.g. while the process was down.
+	Valid bool
+}
+
+// TransformIncrements integrates the rates of a Measurement over their granularity, in time order.
+// The granularity is the shortest interval between two datapoints.
+func TransformIncrements(measurement *m.Measurement) ([]Increment, error) {
+	rule, ok := counterTransformationRules[measurement.Units]
+	if !ok {
+		return nil, errors.New("unit '" + string(measurement.Units) + "' is not a rate")
+	}
+	dataPoints := measurement.DataPoints
+	err := containsValidDataPoints(dataPoints)
+	if err != nil {
+		return nil, err
+	}
+	sortDataPoints(&dataPoints)
+
+	increments := make([]Increment, len(dataPoints))
+	var granularity time.Duration
+	for i, dataPoint := range dataPoints {
+		increments[i].Timestamp, _ = time.Parse(timestampFormat, dataPoint.Timestamp)
+		if i > 0 {
+			interval := increments[i].Timestamp.Sub(increments[i-1].Timestamp)
+			if interval > 0 && (granularity == 0 || interval < granularity) {
+				granularity = interval
+			}
+		}
+	}
+	if granularity == 0 {
+		return nil, ErrUnknownGranularity
+	}
+
+	for i, dataPoint := range dataPoints {
+		if dataPoint.Value == nil {
+			continue
+		}
+		increments[i].Value = float64(*dataPoint.Value) * rule.valueMultiplier * granularity.Seconds()
+		increments[i].Valid = true
+	}
+	return increments, nil
+}
@@ -0,0 +1,54 @@
+package transformer
+
+import (
+	m "mongodbatlas_exporter/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func TestTransformCounterName(t *testing.T) {
+	assert := assert.New(t)
+
+	name, ok := TransformCounterName(&m.MeasurementMetadata{Name: "OPCOUNTER_INSERT", Units: m.SCALAR_PER_SECOND})
+	assert.True(ok)
+	assert.Equal("opcounter_insert_total", name)
+
+	name, ok = TransformCounterName(&m.MeasurementMetadata{Name: "NETWORK_BYTES_IN", Units: m.BYTES_PER_SECOND})
+	assert.True(ok)
+	assert.Equal("network_bytes_in_bytes_total", name)
+
+	_, ok = TransformCounterName(&m.MeasurementMetadata{Name: "CONNECTIONS", Units: m.SCALAR})
+	assert.False(ok)
+}
+
+func TestTransformIncrements(t *testing.T) {
+	assert := assert.New(t)
+	value1, value2 := float32(2), float32(0.5)
+	measurement := &m.Measurement{
+		DataPoints: []*mongodbatlas.DataPoints{
+			{Timestamp: "2021-03-04T16:56:00Z", Value: &value2},
+			{Timestamp: "2021-03-04T16:54:00Z", Value: &value1},
+			{Timestamp: "2021-03-04T16:55:00Z", Value: nil},
+		},
+		Units: m.MEGABYTES_PER_SECOND,
+	}
+
+	increments, err := TransformIncrements(measurement)
+
+	assert.NoError(err)
+	assert.Equal([]Increment{
+		{Timestamp: time.Date(2021, 3, 4, 16, 54, 0, 0, time.UTC), Value: 120 * 1024 * 1024, Valid: true},
+		{Timestamp: time.Date(2021, 3, 4, 16, 55, 0, 0, time.UTC)},
+		{Timestamp: time.Date(2021, 3, 4, 16, 56, 0, 0, time.UTC), Value: 30 * 1024 * 1024, Valid: true},
+	}, increments)
+
+	measurement.DataPoints = measurement.DataPoints[:1]
+	_, err = TransformIncrements(measurement)
+	assert.Equal(ErrUnknownGranularity, err)
+
+	_, err = TransformIncrements(&m.Measurement{DataPoints: measurement.DataPoints, Units: m.SCALAR})
+	assert.Error(err)
+}
@@ -7,4 +7,7 @@ var (
 	//a metric with no datapoints. For example any FTS* metric will
 	//return no datapoints every time if a cluster does not use "full text search".
 	ErrNoData = errors.New("no datapoints are available")
+	//ErrUnknownUnit is returned for units that neither the exporter nor the configuration knows,
+	//e.g. after Atlas introduced a new one.
+	ErrUnknownUnit = errors.New("the unit type seems to be unknown")
 )
@@ -2,6 +2,8 @@ package transformer
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
 
 	m "mongodbatlas_exporter/model"
@@ -19,14 +21,26 @@ func TransformName(measurement *m.MeasurementMetadata) (string, error) {
 		return strings.Join([]string{lowercaseName, unit.nameSuffix}, nameDelimiter), nil
 	}
 
-	var msg string
-	if emptyName {
-		msg += "Can't transform name '" + measurement.Name + "', it seems to be invalid. "
+	if !emptyName {
+		return "", fmt.Errorf("Can't find suffix for unit '%s', %w.", measurement.Units, ErrUnknownUnit)
 	}
 
+	msg := "Can't transform name '" + measurement.Name + "', it seems to be invalid. "
 	if !knownUnit {
 		msg += "Can't find suffix for unit '" + string(measurement.Units) + "', the unit type seems to be unknown."
 	}
 
 	return "", errors.New(msg)
 }
+
+var invalidNameCharacters = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// TransformRawUnitName transforms MeasurementMetadata of an unknown unit into a Prometheus metric name
+// with the lowercased unit as suffix, e.g. EXAMPLE with WIDGETS_PER_SECOND becomes example_widgets_per_second.
+func TransformRawUnitName(measurement *m.MeasurementMetadata) string {
+	unit := invalidNameCharacters.ReplaceAllString(strings.ToLower(string(measurement.Units)), "_")
+	if unit == "" {
+		unit = "unknown"
+	}
+	return strings.ToLower(measurement.Name) + "_" + unit
+}
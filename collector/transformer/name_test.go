@@ -68,3 +68,14 @@ func TestNameTransformer_scalar(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal("example_measurement", promName)
 }
+
+func TestNameTransformer_unknownUnit(t *testing.T) {
+	assert := assert.New(t)
+	measurement := m.MeasurementMetadata{Name: "EXAMPLE_MeasuRemenT", Units: "WIDGETS/SECOND"}
+
+	_, err := TransformName(&measurement)
+
+	assert.ErrorIs(err, ErrUnknownUnit)
+	assert.Equal("example_measurement_widgets_second", TransformRawUnitName(&measurement))
+	assert.Equal(2.0, convertValue(2, measurement.Units))
+}
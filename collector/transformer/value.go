@@ -47,27 +47,97 @@ func containsValidDataPoints(datapoints []*mongodbatlas.DataPoints) error {
 	return nil
 }
 
+//convertValue keeps the values of unknown units as they are, the configured ones are scaled with PromMetric.Scale.
 func convertValue(value float64, unit m.UnitEnum) float64 {
-	multiplier := unitsTransformationRules[unit].valueMultiplier
-	return value * multiplier
+	rules, ok := unitsTransformationRules[unit]
+	if !ok {
+		return value
+	}
+	return value * rules.valueMultiplier
 }
 
 // TransformValue transforms Measurements into float64 for Prometheus metric value
 func TransformValue(measurement *m.Measurement) (float64, error) {
+	value, _, err := TransformValueWithTimestamp(measurement)
+	return value, err
+}
+
+// TransformValueWithTimestamp transforms Measurements into float64 for Prometheus metric value
+// and returns the timestamp of the datapoint the value was taken from.
+// The timestamp is zero if no datapoint has a value.
+func TransformValueWithTimestamp(measurement *m.Measurement) (float64, time.Time, error) {
 	dataPoints := measurement.DataPoints
 	unit := measurement.Units
 	err := containsValidDataPoints(dataPoints)
 	if err != nil {
-		return math.NaN(), err
+		return math.NaN(), time.Time{}, err
 	}
 	sortDataPoints(&dataPoints)
 
 	for i := len(dataPoints) - 1; i >= 0; i-- {
 		value := dataPoints[i].Value
 		if value != nil {
-			return convertValue(float64(*value), unit), nil
+			//the timestamps were validated by containsValidDataPoints.
+			timestamp, _ := time.Parse(timestampFormat, dataPoints[i].Timestamp)
+			return convertValue(float64(*value), unit), timestamp, nil
+		}
+	}
+
+	return float64(0), time.Time{}, nil
+}
+
+// Sample is the transformed value of a single datapoint.
+type Sample struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// TransformDataPoints transforms every datapoint with a value of a Measurement, in time order.
+func TransformDataPoints(measurement *m.Measurement) ([]Sample, error) {
+	dataPoints := measurement.DataPoints
+	err := containsValidDataPoints(dataPoints)
+	if err != nil {
+		return nil, err
+	}
+	sortDataPoints(&dataPoints)
+
+	samples := make([]Sample, 0, len(dataPoints))
+	for _, dataPoint := range dataPoints {
+		if dataPoint.Value == nil {
+			continue
 		}
+		timestamp, _ := time.Parse(timestampFormat, dataPoint.Timestamp)
+		samples = append(samples, Sample{
+			Timestamp: timestamp,
+			Value:     convertValue(float64(*dataPoint.Value), measurement.Units),
+		})
+	}
+	return samples, nil
+}
+
+// Summary aggregates the datapoints of a Measurement over its period.
+type Summary struct {
+	Min, Max, Avg float64
+}
+
+// TransformSummary returns the minimum, maximum and average of the datapoints with a value of a Measurement.
+// It returns ErrNoData if no datapoint has a value.
+func TransformSummary(measurement *m.Measurement) (Summary, error) {
+	samples, err := TransformDataPoints(measurement)
+	if err != nil {
+		return Summary{}, err
+	}
+	if len(samples) == 0 {
+		return Summary{}, ErrNoData
 	}
 
-	return float64(0), nil
+	summary := Summary{Min: samples[0].Value, Max: samples[0].Value}
+	sum := 0.0
+	for _, sample := range samples {
+		summary.Min = math.Min(summary.Min, sample.Value)
+		summary.Max = math.Max(summary.Max, sample.Value)
+		sum += sample.Value
+	}
+	summary.Avg = sum / float64(len(samples))
+	return summary, nil
 }
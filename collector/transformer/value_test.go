@@ -4,6 +4,7 @@ import (
 	"math"
 	m "mongodbatlas_exporter/model"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/atlas/mongodbatlas"
@@ -503,3 +504,84 @@ func TestValueTransformer_moreDataPoints_nilLastValid_duplicatedTimestamps(t *te
 	assert.NoError(err)
 	assert.Equal(float64(value3), promValue)
 }
+
+func TestValueTransformer_timestamp(t *testing.T) {
+	assert := assert.New(t)
+	value1 := float32(2.10016)
+	exampleMeasurement := &m.Measurement{
+		DataPoints: []*mongodbatlas.DataPoints{
+			{
+				Timestamp: "2021-03-04T16:53:06Z",
+				Value:     &value1,
+			},
+			{
+				Timestamp: "2021-03-04T16:54:06Z",
+				Value:     nil,
+			},
+		},
+		Units: m.SCALAR,
+	}
+
+	_, timestamp, err := TransformValueWithTimestamp(exampleMeasurement)
+
+	assert.NoError(err)
+	assert.Equal(time.Date(2021, 3, 4, 16, 53, 6, 0, time.UTC), timestamp)
+
+	exampleMeasurement.DataPoints[0].Value = nil
+	_, timestamp, err = TransformValueWithTimestamp(exampleMeasurement)
+
+	assert.NoError(err)
+	assert.True(timestamp.IsZero())
+}
+
+func TestTransformDataPoints(t *testing.T) {
+	assert := assert.New(t)
+	value1, value2 := float32(1), float32(2)
+	exampleMeasurement := &m.Measurement{
+		DataPoints: []*mongodbatlas.DataPoints{
+			{
+				Timestamp: "2021-03-04T16:55:06Z",
+				Value:     &value2,
+			},
+			{
+				Timestamp: "2021-03-04T16:54:06Z",
+				Value:     nil,
+			},
+			{
+				Timestamp: "2021-03-04T16:53:06Z",
+				Value:     &value1,
+			},
+		},
+		Units: m.KILOBYTES,
+	}
+
+	samples, err := TransformDataPoints(exampleMeasurement)
+
+	assert.NoError(err)
+	assert.Equal([]Sample{
+		{Timestamp: time.Date(2021, 3, 4, 16, 53, 6, 0, time.UTC), Value: 1024},
+		{Timestamp: time.Date(2021, 3, 4, 16, 55, 6, 0, time.UTC), Value: 2048},
+	}, samples)
+}
+
+func TestTransformSummary(t *testing.T) {
+	assert := assert.New(t)
+	value1, value2, value3 := float32(1), float32(6), float32(2)
+	exampleMeasurement := &m.Measurement{
+		DataPoints: []*mongodbatlas.DataPoints{
+			{Timestamp: "2021-03-04T16:53:06Z", Value: &value1},
+			{Timestamp: "2021-03-04T16:54:06Z", Value: nil},
+			{Timestamp: "2021-03-04T16:55:06Z", Value: &value2},
+			{Timestamp: "2021-03-04T16:56:06Z", Value: &value3},
+		},
+		Units: m.KILOBYTES,
+	}
+
+	summary, err := TransformSummary(exampleMeasurement)
+
+	assert.NoError(err)
+	assert.Equal(Summary{Min: 1024, Max: 6144, Avg: 3072}, summary)
+
+	_, err = TransformSummary(&m.Measurement{DataPoints: []*mongodbatlas.DataPoints{{Timestamp: "2021-03-04T16:53:06Z"}}})
+	assert.Equal(ErrNoData, err)
+}
@@ -0,0 +1,578 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DefaultGranularity = "PT1M"
+	DefaultPeriod      = "PT2M"
+	//DefaultRequestsPerMinute is the Atlas limit for most endpoints.
+	DefaultRequestsPerMinute = 100
+	DefaultBurst             = 10
+	//DefaultRequestTimeout bounds a single API request including its retries.
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+// Config is the exporter configuration, either loaded from a file or built from flags.
+type Config struct {
+	//PublicKey and PrivateKey are the default credentials used for the organization
+	//and every project that does not define its own.
+	PublicKey  string `yaml:"public_key"`
+	PrivateKey string `yaml:"private_key"`
+	//OrgID enables the discovery of all projects of the organization.
+	OrgID string `yaml:"org_id"`
+	//Clusters is the default cluster filter for projects that do not define their own.
+	Clusters []string  `yaml:"clusters"`
+	Projects []Project `yaml:"projects"`
+	//Resolution is the granularity and period of every measurement group that does not define its own.
+	Resolution `yaml:",inline"`
+	//Processes and Disks override the resolution of the process and disk measurements.
+	Processes Resolution `yaml:"processes"`
+	Disks     Resolution `yaml:"disks"`
+	Metrics   Metrics    `yaml:"metrics"`
+	//Mappings change how measurements become metrics, the first matching mapping applies.
+	Mappings []Mapping `yaml:"mappings"`
+	//Units add the rules of units the exporter does not know yet, by their Atlas name.
+	Units     map[string]UnitRule `yaml:"units"`
+	Databases Databases           `yaml:"databases"`
+	//PerformanceAdvisor enables the suggested indexes and slow queries of every process.
+	PerformanceAdvisor PerformanceAdvisor `yaml:"performance_advisor"`
+	//RateLimit is the request budget of every project that does not define its own.
+	RateLimit RateLimit `yaml:"rate_limit"`
+	//RequestTimeout is the deadline of every API request, so a hung request can not block a scrape or the discovery.
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+}
+
+// Project holds the settings of a single Atlas project.
+type Project struct {
+	ID         string `yaml:"id"`
+	PublicKey  string `yaml:"public_key"`
+	PrivateKey string `yaml:"private_key"`
+	//Clusters limits the scraped processes to these clusters.
+	//If empty Config.Clusters is used.
+	Clusters []string `yaml:"clusters"`
+	//RateLimit overrides the request budget of the project, unset fields are taken from Config.RateLimit.
+	RateLimit RateLimit `yaml:"rate_limit"`
+}
+
+// RateLimit is a request budget. Requests exceeding it wait until the budget allows them.
+type RateLimit struct {
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	//Burst is the number of requests that can be sent at once after a quiet period.
+	Burst int `yaml:"burst"`
+}
+
+// Resolution selects the datapoints of measurement requests, both are ISO 8601 durations.
+// Granularity is the interval between datapoints and Period the time range until now.
+type Resolution struct {
+	Granularity string `yaml:"granularity"`
+	Period      string `yaml:"period"`
+}
+
+//granularityRetention is how long Atlas keeps the datapoints of each granularity,
+//a longer period would only return empty datapoints.
+var granularityRetention = map[string]time.Duration{
+	"PT10S": 8 * time.Hour,
+	"PT1M":  48 * time.Hour,
+	"PT5M":  48 * time.Hour,
+	"PT1H":  63 * 24 * time.Hour,
+	"P1D":   0,
+}
+
+// Validate checks the resolution against the combinations Atlas accepts.
+func (r Resolution) Validate() error {
+	retention, ok := granularityRetention[r.Granularity]
+	if !ok {
+		return fmt.Errorf("unsupported granularity %q, must be one of PT10S, PT1M, PT5M, PT1H or P1D", r.Granularity)
+	}
+	granularity, _ := parseISO8601Duration(r.Granularity)
+
+	period, err := parseISO8601Duration(r.Period)
+	if err != nil {
+		return err
+	}
+	if period < granularity {
+		return fmt.Errorf("period %s is shorter than the granularity %s", r.Period, r.Granularity)
+	}
+	if retention > 0 && period > retention {
+		return fmt.Errorf("period %s exceeds the %s Atlas keeps datapoints of granularity %s", r.Period, retention, r.Granularity)
+	}
+	return nil
+}
+
+// override returns r with the fields set in other replaced.
+func (r Resolution) override(other Resolution) Resolution {
+	if other.Granularity != "" {
+		r.Granularity = other.Granularity
+	}
+	if other.Period != "" {
+		r.Period = other.Period
+	}
+	return r
+}
+
+var iso8601DurationRegexp = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+//parseISO8601Duration parses the day and time parts of an ISO 8601 duration such as PT2M or P1DT12H,
+//years, months and weeks are not supported.
+func parseISO8601Duration(s string) (time.Duration, error) {
+	match := iso8601DurationRegexp.FindStringSubmatch(s)
+	if match == nil || s == "P" || s == "PT" || s[len(s)-1] == 'T' {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", s)
+	}
+
+	var d time.Duration
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q: %w", s, err)
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
+
+// Databases configures the collection of per database measurements.
+// Every database costs an additional API request per scrape, so they are disabled by default.
+// Include and Exclude match database names, Resolution overrides the global one.
+type Databases struct {
+	Enabled    bool `yaml:"enabled"`
+	Filter     `yaml:",inline"`
+	Resolution `yaml:",inline"`
+}
+
+// PerformanceAdvisor configures the collection of Performance Advisor results.
+// Like databases they cost additional API requests per process and scrape, so they are disabled by default.
+type PerformanceAdvisor struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// Metrics selects the exported measurements by their Atlas name, e.g. CACHE_BYTES_READ_INTO,
+// and by the name of their Prometheus metric, e.g. mongodbatlas_processes_stats_cache_bytes_read_into_bytes.
+// Filtered measurements are dropped with the metadata, so they never become metrics.
+type Metrics struct {
+	Filter `yaml:",inline"`
+	Names  Filter `yaml:"names"`
+}
+
+// Mapping changes the metric of the measurements whose Atlas name matches Match.
+// Name, help and label values can refer to the groups of Match as $1 or ${name}.
+type Mapping struct {
+	Match Regexp `yaml:"match"`
+	//Name replaces the Atlas name, it is lowercased and the unit suffix is appended.
+	Name string `yaml:"name"`
+	//FullName makes Name the whole metric name after the namespace, without the prefix of the resource
+	//(processes_stats, disks_stats, ...) and the unit suffix.
+	FullName bool `yaml:"full_name"`
+	//Labels are added to the metric, their values are lowercased.
+	Labels map[string]string `yaml:"labels"`
+	Help   string            `yaml:"help"`
+	//Type is the Prometheus type of the metric: gauge, counter or untyped.
+	Type string `yaml:"type"`
+}
+
+// MappedMeasurement is a Mapping applied to the name of a measurement.
+type MappedMeasurement struct {
+	Name     string
+	FullName bool
+	Labels   map[string]string
+	Help     string
+	Type     string
+	//Pattern is the expression of the Mapping, it describes all measurements sharing the metric.
+	Pattern string
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func (m *Mapping) validate() error {
+	if m.Match.Regexp == nil {
+		return errors.New("mapping without match")
+	}
+	switch m.Type {
+	case "", "gauge", "counter", "untyped":
+	default:
+		return fmt.Errorf("mapping %s has unsupported type %q, must be gauge, counter or untyped", m.Match.original, m.Type)
+	}
+	if m.FullName && m.Name == "" {
+		return fmt.Errorf("mapping %s has full_name without name", m.Match.original)
+	}
+	distinct := strings.Contains(m.Name, "$")
+	for name, value := range m.Labels {
+		if !labelNameRegexp.MatchString(name) {
+			return fmt.Errorf("mapping %s has invalid label name %q", m.Match.original, name)
+		}
+		distinct = distinct || strings.Contains(value, "$")
+	}
+	//measurements sharing a metric must be told apart by a group of the expression,
+	//identical series would fail every scrape.
+	if m.Name != "" && !distinct && regexp.QuoteMeta(m.Match.original) != m.Match.original {
+		return fmt.Errorf("mapping %s maps every matching measurement to the same series, name or labels must refer to a group of match", m.Match.original)
+	}
+	return nil
+}
+
+// UnitRule converts the measurements of a unit the exporter does not know yet.
+// Measurements of units without a rule are exported with the lowercased unit as suffix and a unit label.
+type UnitRule struct {
+	//Suffix is appended to the metric name, e.g. _bytes, it may be empty.
+	Suffix string `yaml:"suffix"`
+	//Multiplier converts the values into the base unit of the suffix, it defaults to 1.
+	Multiplier float64 `yaml:"multiplier"`
+}
+
+var unitSuffixRegexp = regexp.MustCompile(`^(_[a-zA-Z0-9_]*)?$`)
+
+func (u UnitRule) validate(name string) error {
+	if name == "" {
+		return errors.New("unit without name")
+	}
+	if !unitSuffixRegexp.MatchString(u.Suffix) {
+		return fmt.Errorf("unit %s has invalid suffix %q, it must be empty or start with _", name, u.Suffix)
+	}
+	if u.Multiplier < 0 {
+		return fmt.Errorf("unit %s has negative multiplier", name)
+	}
+	return nil
+}
+
+// MapMeasurement applies the first Mapping matching the Atlas measurement name.
+// ok is false if no Mapping matches.
+func (c *Config) MapMeasurement(name string) (mapped MappedMeasurement, ok bool) {
+	for _, mapping := range c.Mappings {
+		match := mapping.Match.FindStringSubmatchIndex(name)
+		if match == nil {
+			continue
+		}
+		expand := func(template string) string {
+			return string(mapping.Match.ExpandString(nil, template, name, match))
+		}
+
+		mapped = MappedMeasurement{
+			Name:     name,
+			FullName: mapping.FullName,
+			Help:     expand(mapping.Help),
+			Type:     mapping.Type,
+			Pattern:  mapping.Match.original,
+		}
+		if mapping.Name != "" {
+			mapped.Name = expand(mapping.Name)
+		}
+		if len(mapping.Labels) > 0 {
+			mapped.Labels = make(map[string]string, len(mapping.Labels))
+			for label, value := range mapping.Labels {
+				mapped.Labels[label] = strings.ToLower(expand(value))
+			}
+		}
+		return mapped, true
+	}
+	return MappedMeasurement{}, false
+}
+
+// Filter selects measurements by their Atlas name, e.g. CACHE_BYTES_READ_INTO, or databases by their name.
+// A name is kept if it matches any Include expression or glob (or both are empty)
+// and matches no Exclude expression or glob.
+type Filter struct {
+	Include []Regexp `yaml:"include"`
+	Exclude []Regexp `yaml:"exclude"`
+	//IncludeGlobs and ExcludeGlobs are shell patterns, e.g. CACHE_*.
+	IncludeGlobs []Glob `yaml:"include_globs"`
+	ExcludeGlobs []Glob `yaml:"exclude_globs"`
+}
+
+// Regexp is a regular expression that is anchored at both ends and can be unmarshalled from YAML.
+type Regexp struct {
+	*regexp.Regexp
+	original string
+}
+
+// NewRegexp compiles s as an anchored regular expression.
+func NewRegexp(s string) (Regexp, error) {
+	re, err := regexp.Compile("^(?:" + s + ")$")
+	return Regexp{Regexp: re, original: s}, err
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (re *Regexp) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	r, err := NewRegexp(s)
+	if err != nil {
+		return err
+	}
+	*re = r
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (re Regexp) MarshalYAML() (interface{}, error) {
+	return re.original, nil
+}
+
+// Glob is a shell pattern that can be unmarshalled from YAML.
+// * matches any sequence of characters, ? any single character and [...] a character class.
+type Glob struct {
+	Regexp
+}
+
+// NewGlob compiles the shell pattern s.
+func NewGlob(s string) (Glob, error) {
+	var expression strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*':
+			expression.WriteString(".*")
+		case '?':
+			expression.WriteString(".")
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return Glob{}, fmt.Errorf("unterminated character class in glob %q", s)
+			}
+			class := s[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expression.WriteString("[" + class + "]")
+			i += end
+		default:
+			expression.WriteString(regexp.QuoteMeta(s[i : i+1]))
+		}
+	}
+	re, err := NewRegexp(expression.String())
+	re.original = s
+	return Glob{Regexp: re}, err
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (g *Glob) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	glob, err := NewGlob(s)
+	if err != nil {
+		return err
+	}
+	*g = glob
+	return nil
+}
+
+// Allowed reports whether the name passes the filter.
+func (f *Filter) Allowed(name string) bool {
+	included := len(f.Include) == 0 && len(f.IncludeGlobs) == 0
+	for _, re := range f.Include {
+		if re.MatchString(name) {
+			included = true
+			break
+		}
+	}
+	for _, glob := range f.IncludeGlobs {
+		if glob.MatchString(name) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+
+	for _, re := range f.Exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	for _, glob := range f.ExcludeGlobs {
+		if glob.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Load reads and validates the configuration file.
+// publicKey and privateKey are used when the file does not define default credentials,
+// so secrets can stay in the environment.
+func Load(filename, publicKey, privateKey string) (*Config, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse config file %s: %w", filename, err)
+	}
+
+	if cfg.PublicKey == "" && cfg.PrivateKey == "" {
+		cfg.PublicKey, cfg.PrivateKey = publicKey, privateKey
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", filename, err)
+	}
+	return cfg, nil
+}
+
+func parse(content []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, err
+	}
+	cfg.setDefaults()
+	return cfg, nil
+}
+
+// FromFlags builds the configuration for the command line flags.
+func FromFlags(publicKey, privateKey, orgID string, projectIDs, clusters []string) *Config {
+	cfg := &Config{
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+		OrgID:      orgID,
+		Clusters:   clusters,
+	}
+	for _, projectID := range projectIDs {
+		cfg.Projects = append(cfg.Projects, Project{ID: projectID})
+	}
+	cfg.setDefaults()
+	return cfg
+}
+
+func (c *Config) setDefaults() {
+	if c.Granularity == "" {
+		c.Granularity = DefaultGranularity
+	}
+	if c.Period == "" {
+		c.Period = DefaultPeriod
+	}
+	if c.RateLimit.RequestsPerMinute == 0 {
+		c.RateLimit.RequestsPerMinute = DefaultRequestsPerMinute
+	}
+	if c.RateLimit.Burst == 0 {
+		c.RateLimit.Burst = DefaultBurst
+	}
+	if c.RequestTimeout == 0 {
+		c.RequestTimeout = DefaultRequestTimeout
+	}
+}
+
+// Validate checks that every project can be scraped.
+func (c *Config) Validate() error {
+	if c.OrgID == "" && len(c.Projects) == 0 {
+		return errors.New("either org_id or at least one project has to be defined")
+	}
+
+	seen := make(map[string]bool, len(c.Projects))
+	for _, project := range c.Projects {
+		if project.ID == "" {
+			return errors.New("project without id")
+		}
+		if seen[project.ID] {
+			return fmt.Errorf("project %s is defined multiple times", project.ID)
+		}
+		seen[project.ID] = true
+
+		if (project.PublicKey == "") != (project.PrivateKey == "") {
+			return fmt.Errorf("project %s needs both public_key and private_key", project.ID)
+		}
+		if project.PublicKey == "" && c.PublicKey == "" {
+			return fmt.Errorf("project %s has no credentials and no default credentials are defined", project.ID)
+		}
+	}
+
+	if c.OrgID != "" && c.PublicKey == "" {
+		return errors.New("org_id requires default credentials")
+	}
+
+	if c.RequestTimeout < 0 {
+		return errors.New("request_timeout must be positive")
+	}
+
+	resolutions := map[string]Resolution{
+		"processes": c.ProcessResolution(),
+		"disks":     c.DiskResolution(),
+		"databases": c.DatabaseResolution(),
+	}
+	for group, resolution := range resolutions {
+		if err := resolution.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", group, err)
+		}
+	}
+
+	for i := range c.Mappings {
+		if err := c.Mappings[i].validate(); err != nil {
+			return err
+		}
+	}
+	for name, unit := range c.Units {
+		if err := unit.validate(name); err != nil {
+			return err
+		}
+	}
+
+	if c.RateLimit.RequestsPerMinute < 0 || c.RateLimit.Burst < 0 {
+		return errors.New("rate_limit values must be positive")
+	}
+	for _, project := range c.Projects {
+		if project.RateLimit.RequestsPerMinute < 0 || project.RateLimit.Burst < 0 {
+			return fmt.Errorf("rate_limit values of project %s must be positive", project.ID)
+		}
+	}
+	return nil
+}
+
+// ProjectClusters returns the cluster filter of a project.
+func (c *Config) ProjectClusters(projectID string) []string {
+	for _, project := range c.Projects {
+		if project.ID == projectID && len(project.Clusters) > 0 {
+			return project.Clusters
+		}
+	}
+	return c.Clusters
+}
+
+// ProcessResolution returns the resolution of the process measurements.
+func (c *Config) ProcessResolution() Resolution {
+	return c.Resolution.override(c.Processes)
+}
+
+// DiskResolution returns the resolution of the disk measurements.
+func (c *Config) DiskResolution() Resolution {
+	return c.Resolution.override(c.Disks)
+}
+
+// DatabaseResolution returns the resolution of the database measurements.
+func (c *Config) DatabaseResolution() Resolution {
+	return c.Resolution.override(c.Databases.Resolution)
+}
+
+// ProjectRateLimit returns the request budget of a project.
+func (c *Config) ProjectRateLimit(projectID string) RateLimit {
+	limit := c.RateLimit
+	for _, project := range c.Projects {
+		if project.ID != projectID {
+			continue
+		}
+		if project.RateLimit.RequestsPerMinute > 0 {
+			limit.RequestsPerMinute = project.RateLimit.RequestsPerMinute
+		}
+		if project.RateLimit.Burst > 0 {
+			limit.Burst = project.RateLimit.Burst
+		}
+	}
+	return limit
+}
@@ -0,0 +1,325 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `
+public_key: default-public
+private_key: default-private
+clusters: [default-cluster]
+projects:
+  - id: project-a
+  - id: project-b
+    public_key: b-public
+    private_key: b-private
+    clusters: [cluster-b]
+metrics:
+  include: ["CACHE_.*", "DISK_.*"]
+  exclude: [".*_IOPS_.*"]
+`
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := parse([]byte(testConfig))
+
+	assert.NoError(err)
+	assert.NoError(cfg.Validate())
+	assert.Len(cfg.Projects, 2)
+	assert.Equal("b-public", cfg.Projects[1].PublicKey)
+	//defaults are applied when the file does not define them.
+	assert.Equal(DefaultGranularity, cfg.Granularity)
+	assert.Equal(DefaultPeriod, cfg.Period)
+
+	assert.Equal([]string{"default-cluster"}, cfg.ProjectClusters("project-a"))
+	assert.Equal([]string{"cluster-b"}, cfg.ProjectClusters("project-b"))
+	assert.Equal([]string{"default-cluster"}, cfg.ProjectClusters("discovered-project"))
+}
+
+func TestParse_unknownField(t *testing.T) {
+	_, err := parse([]byte("projects:\n  - id: a\n    cluster: typo\n"))
+
+	assert.Error(t, err)
+}
+
+func TestParse_invalidRegexp(t *testing.T) {
+	_, err := parse([]byte("metrics:\n  include: [\"(\"]\n"))
+
+	assert.Error(t, err)
+}
+
+func TestFilterAllowed(t *testing.T) {
+	cfg, err := parse([]byte(testConfig))
+	assert.NoError(t, err)
+
+	testCases := map[string]bool{
+		"CACHE_BYTES_READ_INTO":     true,
+		"DISK_PARTITION_SPACE_USED": true,
+		"DISK_PARTITION_IOPS_READ":  false,
+		"OPCOUNTER_INSERT":          false,
+		//expressions are anchored.
+		"XCACHE_BYTES_READ_INTO": false,
+	}
+
+	for name, allowed := range testCases {
+		assert.Equal(t, allowed, cfg.Metrics.Allowed(name), name)
+	}
+
+	//an empty filter allows everything.
+	assert.True(t, (&Filter{}).Allowed("OPCOUNTER_INSERT"))
+}
+
+func TestValidate(t *testing.T) {
+	testCases := map[string]*Config{
+		"no projects": {
+			PublicKey: "public", PrivateKey: "private",
+		},
+		"no id": {
+			PublicKey: "public", PrivateKey: "private",
+			Projects: []Project{{}},
+		},
+		"duplicate project": {
+			PublicKey: "public", PrivateKey: "private",
+			Projects: []Project{{ID: "a"}, {ID: "a"}},
+		},
+		"no credentials": {
+			Projects: []Project{{ID: "a"}},
+		},
+		"partial project credentials": {
+			PublicKey: "public", PrivateKey: "private",
+			Projects: []Project{{ID: "a", PublicKey: "public"}},
+		},
+		"org without default credentials": {
+			OrgID:    "org",
+			Projects: []Project{{ID: "a", PublicKey: "public", PrivateKey: "private"}},
+		},
+	}
+
+	for name, cfg := range testCases {
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+// TestLoad checks that the credentials passed as flags are used
+// when the file does not define any.
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.yml")
+	assert.NoError(ioutil.WriteFile(filename, []byte("org_id: org\n"), 0600))
+
+	cfg, err := Load(filename, "flag-public", "flag-private")
+
+	assert.NoError(err)
+	assert.Equal("flag-public", cfg.PublicKey)
+	assert.Equal("flag-private", cfg.PrivateKey)
+
+	_, err = Load(filename, "", "")
+	assert.Error(err)
+}
+
+func TestParse_databases(t *testing.T) {
+	cfg, err := parse([]byte("databases:\n  enabled: true\n  exclude: [admin, local]\n"))
+
+	assert.NoError(t, err)
+	assert.True(t, cfg.Databases.Enabled)
+	assert.True(t, cfg.Databases.Allowed("tenant"))
+	assert.False(t, cfg.Databases.Allowed("admin"))
+}
+
+func TestParse_performanceAdvisor(t *testing.T) {
+	cfg, err := parse([]byte("performance_advisor:\n  enabled: true\n"))
+	assert.NoError(t, err)
+	assert.True(t, cfg.PerformanceAdvisor.Enabled)
+}
+
+func TestProjectRateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := parse([]byte("rate_limit:\n  requests_per_minute: 60\nprojects:\n  - id: a\n    rate_limit:\n      burst: 2\n  - id: b\n"))
+
+	assert.NoError(err)
+	assert.Equal(RateLimit{RequestsPerMinute: 60, Burst: 2}, cfg.ProjectRateLimit("a"))
+	//unset values are inherited from the global rate limit and its defaults.
+	assert.Equal(RateLimit{RequestsPerMinute: 60, Burst: DefaultBurst}, cfg.ProjectRateLimit("b"))
+	assert.Equal(RateLimit{RequestsPerMinute: 60, Burst: DefaultBurst}, cfg.ProjectRateLimit("discovered-project"))
+
+	cfg.Projects[0].RateLimit.Burst = -1
+	assert.Error(cfg.Validate())
+}
+
+func TestParse_requestTimeout(t *testing.T) {
+	cfg, err := parse([]byte("request_timeout: 10s\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Second, cfg.RequestTimeout)
+
+	cfg, err = parse([]byte("{}"))
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRequestTimeout, cfg.RequestTimeout)
+}
+
+func TestParse_resolution(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := parse([]byte("granularity: PT5M\nperiod: PT10M\nprocesses:\n  granularity: PT10S\n  period: PT1M\ndatabases:\n  enabled: true\n  period: PT1H\n"))
+
+	assert.NoError(err)
+	assert.Equal(Resolution{Granularity: "PT10S", Period: "PT1M"}, cfg.ProcessResolution())
+	//unset values are inherited from the global resolution.
+	assert.Equal(Resolution{Granularity: "PT5M", Period: "PT10M"}, cfg.DiskResolution())
+	assert.Equal(Resolution{Granularity: "PT5M", Period: "PT1H"}, cfg.DatabaseResolution())
+
+	cfg, err = parse([]byte("{}"))
+	assert.NoError(err)
+	assert.Equal(Resolution{Granularity: DefaultGranularity, Period: DefaultPeriod}, cfg.DiskResolution())
+}
+
+func TestResolutionValidate(t *testing.T) {
+	testCases := map[Resolution]bool{
+		{Granularity: "PT1M", Period: "PT2M"}:    true,
+		{Granularity: "PT10S", Period: "PT30S"}:  true,
+		{Granularity: "P1D", Period: "P400D"}:    true,
+		{Granularity: "PT1H", Period: "P1DT12H"}: true,
+		{Granularity: "PT2M", Period: "PT4M"}:    false,
+		{Granularity: "PT5M", Period: "PT1M"}:    false,
+		{Granularity: "PT10S", Period: "PT9H"}:   false,
+		{Granularity: "PT1M", Period: "2m"}:      false,
+		{Granularity: "PT1M", Period: "PT"}:      false,
+		{Granularity: "PT1M", Period: "P1DT"}:    false,
+	}
+
+	for resolution, valid := range testCases {
+		if valid {
+			assert.NoError(t, resolution.Validate(), resolution)
+		} else {
+			assert.Error(t, resolution.Validate(), resolution)
+		}
+	}
+
+	cfg, err := parse([]byte("org_id: org\npublic_key: a\nprivate_key: b\ndisks:\n  granularity: PT10M\n"))
+	assert.NoError(t, err)
+	assert.Error(t, cfg.Validate())
+}
+
+func TestParse_metricNames(t *testing.T) {
+	cfg, err := parse([]byte("metrics:\n  exclude_globs: [\"FTS_*\"]\n  names:\n    include_globs: [\"mongodbatlas_processes_stats_*\", \"mongodbatlas_disks_stats_disk_partition_space_*\"]\n    exclude: [\".*_ratio\"]\n"))
+	assert.NoError(t, err)
+
+	assert.False(t, cfg.Metrics.Allowed("FTS_MEMORY_RESIDENT"))
+	assert.True(t, cfg.Metrics.Allowed("OPCOUNTER_INSERT"))
+
+	testCases := map[string]bool{
+		"mongodbatlas_processes_stats_connections":                     true,
+		"mongodbatlas_processes_stats_opcounter_insert_ratio":          false,
+		"mongodbatlas_disks_stats_disk_partition_space_used_bytes":     true,
+		"mongodbatlas_disks_stats_disk_partition_iops_read_ratio":      false,
+		"mongodbatlas_databases_stats_database_data_size_bytes":        false,
+		"mongodbatlas_disks_stats_disk_partition_latency_read_seconds": false,
+	}
+	for name, allowed := range testCases {
+		assert.Equal(t, allowed, cfg.Metrics.Names.Allowed(name), name)
+	}
+}
+
+func TestNewGlob(t *testing.T) {
+	testCases := map[string]map[string]bool{
+		"CACHE_*":         {"CACHE_BYTES_READ_INTO": true, "XCACHE_BYTES": false},
+		"OPCOUNTER_?????": {"OPCOUNTER_QUERY": true, "OPCOUNTER_INSERT": false},
+		"DISK_[!L]*":      {"DISK_PARTITION_IOPS_READ": true, "DISK_LATENCY": false},
+		"a.b":             {"a.b": true, "axb": false},
+	}
+	for pattern, names := range testCases {
+		glob, err := NewGlob(pattern)
+		assert.NoError(t, err, pattern)
+		for name, matches := range names {
+			assert.Equal(t, matches, glob.MatchString(name), pattern+" "+name)
+		}
+	}
+
+	_, err := NewGlob("DISK_[")
+	assert.Error(t, err)
+}
+
+func TestMapMeasurement(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := parse([]byte(`
+org_id: org
+public_key: a
+private_key: b
+mappings:
+  - match: "CACHE_BYTES_(.*)"
+    name: cache
+    labels:
+      direction: "$1"
+    help: "Bytes moved by the WiredTiger cache, by direction."
+  - match: "CACHE_.*"
+    type: counter
+`))
+	assert.NoError(err)
+	assert.NoError(cfg.Validate())
+
+	mapped, ok := cfg.MapMeasurement("CACHE_BYTES_READ_INTO")
+	assert.True(ok)
+	assert.Equal(MappedMeasurement{
+		Name:    "cache",
+		Labels:  map[string]string{"direction": "read_into"},
+		Help:    "Bytes moved by the WiredTiger cache, by direction.",
+		Pattern: "CACHE_BYTES_(.*)",
+	}, mapped)
+
+	//the first matching mapping applies, without name the Atlas name is kept.
+	mapped, ok = cfg.MapMeasurement("CACHE_DIRTY_BYTES")
+	assert.True(ok)
+	assert.Equal(MappedMeasurement{Name: "CACHE_DIRTY_BYTES", Type: "counter", Pattern: "CACHE_.*"}, mapped)
+
+	_, ok = cfg.MapMeasurement("CONNECTIONS")
+	assert.False(ok)
+
+	cfg.Mappings[1].Type = "histogram"
+	assert.Error(cfg.Validate())
+	cfg.Mappings[1].Type = ""
+	cfg.Mappings[0].Labels["invalid-name"] = "x"
+	assert.Error(cfg.Validate())
+	delete(cfg.Mappings[0].Labels, "invalid-name")
+
+	//the measurements of an expression without a group in name or labels would share a series.
+	cfg.Mappings[0].Labels["direction"] = "all"
+	assert.Error(cfg.Validate())
+	//unless the expression matches a single name.
+	cfg.Mappings[0].Match, err = NewRegexp("CACHE_BYTES_READ_INTO")
+	assert.NoError(err)
+	assert.NoError(cfg.Validate())
+
+	cfg.Mappings[0].Name = ""
+	cfg.Mappings[0].FullName = true
+	assert.Error(cfg.Validate())
+}
+
+func TestParse_units(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg, err := parse([]byte("org_id: org\npublic_key: a\nprivate_key: b\nunits:\n  KIBIBYTES: {suffix: _bytes, multiplier: 1024}\n  WIDGETS: {}\n"))
+
+	assert.NoError(err)
+	assert.NoError(cfg.Validate())
+	assert.Equal(map[string]UnitRule{
+		"KIBIBYTES": {Suffix: "_bytes", Multiplier: 1024},
+		"WIDGETS":   {},
+	}, cfg.Units)
+
+	cfg.Units["WIDGETS"] = UnitRule{Suffix: "widgets"}
+	assert.Error(cfg.Validate())
+	cfg.Units["WIDGETS"] = UnitRule{Multiplier: -1}
+	assert.Error(cfg.Validate())
+}
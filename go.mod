@@ -9,8 +9,10 @@ require (
 	github.com/mongodb-forks/digest v1.0.3
 	github.com/onsi/gomega v1.4.3
 	github.com/prometheus/client_golang v1.9.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/common v0.18.0
 	github.com/stretchr/testify v1.7.0
 	go.mongodb.org/atlas v0.12.0
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -2,7 +2,8 @@ package main
 
 import (
 	"fmt"
-	"mongodbatlas_exporter/mongodbatlas"
+	"mongodbatlas_exporter/collector"
+	"mongodbatlas_exporter/measurer"
 	"mongodbatlas_exporter/registerer"
 	"net/http"
 	"os"
@@ -21,11 +22,38 @@ const (
 )
 
 var (
-	listenAddress   = kingpin.Flag("listen-address", "The address to listen on for HTTP requests.").Default(":9905").Envar("LISTEN_ADDRESS").String()
-	atlasPublicKey  = kingpin.Flag("atlas.public-key", "Atlas API public key").Envar("ATLAS_PUBLIC_KEY").String()
-	atlasPrivateKey = kingpin.Flag("atlas.private-key", "Atlas API private key").Envar("ATLAS_PRIVATE_KEY").String()
-	atlasProjectID  = kingpin.Flag("atlas.project-id", "Atlas project id (group id) to scrape metrics from").Envar("ATLAS_PROJECT_ID").String()
-	atlasClusters   = kingpin.Flag("atlas.cluster", "Atlas cluster name to scrape metrics from. Can be defined multiple times. If not defined all clusters in the project will be scraped").Strings()
+	serveCommand      = kingpin.Command("serve", "Serve the metrics of the Atlas API.").Default()
+	backfillCommand   = kingpin.Command("backfill", "Write the process and disk measurements of a past time range as OpenMetrics text for promtool tsdb create-blocks-from openmetrics.")
+	backfillStart     = backfillCommand.Flag("start", "Start of the time range, RFC3339.").Required().String()
+	backfillEnd       = backfillCommand.Flag("end", "End of the time range, RFC3339. Defaults to now.").String()
+	backfillOutput    = backfillCommand.Flag("output", "Path of the OpenMetrics file, - writes to stdout.").Default("-").String()
+	backfillWindow    = backfillCommand.Flag("window", "Time range of a single measurements request.").Default("6h").Duration()
+	configFile        = kingpin.Flag("config.file", "Path to the configuration file. If defined the atlas.* flags other than the keys are ignored. Reloaded on SIGHUP or POST /-/reload.").Envar("CONFIG_FILE").String()
+	listenAddress     = kingpin.Flag("listen-address", "The address to listen on for HTTP requests.").Default(":9905").Envar("LISTEN_ADDRESS").String()
+	atlasPublicKey    = kingpin.Flag("atlas.public-key", "Atlas API public key").Envar("ATLAS_PUBLIC_KEY").String()
+	atlasPrivateKey   = kingpin.Flag("atlas.private-key", "Atlas API private key").Envar("ATLAS_PRIVATE_KEY").String()
+	atlasProjectIDs   = kingpin.Flag("atlas.project-id", "Atlas project id (group id) to scrape metrics from. Can be defined multiple times.").Envar("ATLAS_PROJECT_ID").Strings()
+	atlasOrgID        = kingpin.Flag("atlas.org-id", "Atlas organization id. If defined all projects of the organization are discovered and scraped").Envar("ATLAS_ORG_ID").String()
+	atlasClusters     = kingpin.Flag("atlas.cluster", "Atlas cluster name to scrape metrics from. Can be defined multiple times. If not defined all clusters in the project will be scraped").Strings()
+	collectDatabases  = kingpin.Flag("collector.databases", "Enable per database measurements of every process. Costs an additional API request per database and scrape.").Default("false").Bool()
+	collectAdvisor    = kingpin.Flag("collector.perf-advisor", "Enable the Performance Advisor suggested indexes and slow queries of every process. Costs two additional API requests per process and scrape.").Default("false").Bool()
+	pollInterval      = kingpin.Flag("poll.interval", "Fetch process measurements in the background at this interval and serve scrapes from the cache. 0 fetches them on every scrape.").Default("0s").Duration()
+	pollMaxStaleness  = kingpin.Flag("poll.max-staleness", "Cached process measurements older than this are not reported. 0 reports them forever.").Default("5m").Duration()
+	pollConcurrency   = kingpin.Flag("poll.concurrency", "Number of processes polled at the same time.").Default("4").Int()
+	metadataRefresh   = kingpin.Flag("metadata.refresh-interval", "Fetch the measurement metadata of every process again at this interval, so measurements Atlas adds or removes are picked up. 0 never refreshes it.").Default("1h").Duration()
+	fetchConcurrency  = kingpin.Flag("fetch.concurrency", "Maximum number of measurement requests of all processes, disks and databases sent at the same time.").Default("8").Int()
+	fetchTimeout      = kingpin.Flag("fetch.timeout", "Deadline of the Atlas API requests of background polls and of scrapes without the X-Prometheus-Scrape-Timeout-Seconds header.").Default("30s").Duration()
+	nativeTimestamps  = kingpin.Flag("measurements.timestamps", "Report measurements with the timestamp of their Atlas datapoint instead of the scrape time. Every datapoint is reported only once.").Default("false").Bool()
+	aggregates        = kingpin.Flag("measurements.aggregates", "Additionally report the minimum, maximum and average of the datapoints of every measurement's period as _min, _max and _avg gauges.").Default("false").Bool()
+	counters          = kingpin.Flag("measurements.counters", "Additionally report per second rates such as OPCOUNTER_* as _total counters integrating their datapoints.").Default("false").Bool()
+	timeoutOffset     = kingpin.Flag("scrape.timeout-offset", "Offset to subtract from the scrape timeout Prometheus sends, to leave time for writing the response.").Default("500ms").Duration()
+	projectCollectors = map[string]*bool{
+		"clusters": kingpin.Flag("collector.clusters", "Enable the collector for cluster state and configuration. Costs an additional API request per project and scrape.").Default("false").Bool(),
+		"alerts":   kingpin.Flag("collector.alerts", "Enable the collector for open Atlas alerts.").Default("false").Bool(),
+		"backup":   kingpin.Flag("collector.backup", "Enable the collector for Cloud Provider Snapshots and restore jobs. Costs two additional API requests per cluster and scrape.").Default("false").Bool(),
+		"events":   kingpin.Flag("collector.events", "Enable the collector counting Atlas project events by type.").Default("false").Bool(),
+	}
+	eventsStateFile = kingpin.Flag("collector.events.state-file", "Path to the file persisting the last counted event of every project, so restarts do not count events twice. If empty the state is kept in memory only.").Envar("EVENTS_STATE_FILE").String()
 	logLevel        = kingpin.Flag("log-level", "Printed logs level.").Default("info").Enum("error", "warn", "info", "debug")
 	up              = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "mongodbatlas_up",
@@ -35,7 +63,7 @@ var (
 
 func main() {
 	kingpin.Version(version.Print(name))
-	kingpin.Parse()
+	command := kingpin.Parse()
 
 	logger, err := createLogger(*logLevel)
 	if err != nil {
@@ -43,21 +71,77 @@ func main() {
 		os.Exit(1)
 	}
 
+	if command == backfillCommand.FullCommand() {
+		if err := runBackfill(logger); err != nil {
+			level.Error(logger).Log("msg", "failed to backfill", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	prometheus.MustRegister(version.NewCollector(name))
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "mongodbatlas_exporter_help_catalogue_info",
+		Help:        "Version of the built-in catalogue describing the known measurements.",
+		ConstLabels: prometheus.Labels{"version": measurer.CatalogueVersion()},
+	}, func() float64 { return 1 }))
 
-	client, err := mongodbatlas.NewClient(logger, *atlasPublicKey, *atlasPrivateKey, *atlasProjectID, *atlasClusters)
+	client, err := newClient(logger)
 	if err != nil {
 		level.Error(logger).Log("msg", "failed to create MongoDB Atlas client", "err", err)
 		os.Exit(1)
 	}
+	client.ApplyRateLimits()
 
-	processRegister := registerer.NewProcessRegisterer(logger, client, time.Minute)
+	processOptions := collector.ProcessOptions{
+		Pool:       collector.NewFetchPool(*fetchConcurrency, *fetchTimeout),
+		Timestamps: *nativeTimestamps,
+		Aggregates: *aggregates,
+		Counters:   *counters,
+	}
+	//the collectors of the registerers are gathered with the context of every scrape.
+	registry := collector.NewRegistry()
+	processRegister := registerer.NewProcessRegisterer(logger, registry, client, time.Minute, registerer.PollOptions{
+		Interval:         *pollInterval,
+		MaxStaleness:     *pollMaxStaleness,
+		Concurrency:      *pollConcurrency,
+		MetadataInterval: *metadataRefresh,
+	}, processOptions)
 
 	go processRegister.Observe()
 
+	eventMarks, err := collector.LoadEventMarks(*eventsStateFile)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to load the events state file", "err", err)
+		os.Exit(1)
+	}
+
+	availableFactories := registerer.NewProjectCollectorFactories(eventMarks, *fetchTimeout)
+	factories := make(map[string]registerer.ProjectCollectorFactory, len(projectCollectors))
+	for name, enabled := range projectCollectors {
+		if *enabled {
+			factories[name] = availableFactories[name]
+		}
+	}
+	projectRegister := registerer.NewProjectRegisterer(logger, registry, client, time.Minute, factories)
+
+	go projectRegister.Observe()
+
+	reloader := &reloader{
+		logger:      logger,
+		client:      client,
+		registerers: []clientSetter{processRegister, projectRegister},
+	}
+	configReloadSuccess.Set(1)
+	configReloadSeconds.SetToCurrentTime()
+	go reloader.watchSignals()
+
 	up.Set(1)
 
-	http.Handle("/metrics", promhttp.Handler())
+	metricsHandler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, registry.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}))
+	http.Handle("/metrics", collector.InstrumentScrapeTimeout(*timeoutOffset, *fetchTimeout, metricsHandler))
+	http.Handle("/probe", collector.InstrumentScrapeTimeout(*timeoutOffset, *fetchTimeout, probeHandler(logger, reloader.Client, processOptions)))
+	http.Handle("/-/reload", reloader.handler())
 
 	if err := http.ListenAndServe(*listenAddress, nil); err != nil {
 		level.Error(logger).Log("msg", "failed to start the http server", "err", err)
@@ -0,0 +1,42 @@
+package measurer
+
+import "go.mongodb.org/atlas/mongodbatlas"
+
+//AlertStatuses are the statuses of alerts that are not resolved yet.
+//TRACKING alerts met their condition but did not exceed the notification delay yet.
+var AlertStatuses = []string{"OPEN", "TRACKING"}
+
+// Alert contains the identifying fields of an Atlas alert.
+// Fields that only apply to some event types are empty for the others.
+type Alert struct {
+	AlertConfigID   string
+	EventTypeName   string
+	Status          string
+	ClusterName     string
+	ReplicaSetName  string
+	HostnameAndPort string
+	//MetricName is only present for OUTSIDE_METRIC_THRESHOLD alerts.
+	MetricName string
+}
+
+func (a *Alert) PromVariableLabelNames() []string {
+	return []string{"alert_config_id", "event_type", "status", "cluster", "rs_name", "host", "metric_name"}
+}
+
+func (a *Alert) PromVariableLabelValues() []string {
+	return []string{a.AlertConfigID, a.EventTypeName, a.Status, a.ClusterName, a.ReplicaSetName, a.HostnameAndPort, a.MetricName}
+}
+
+//AlertFromMongodbAtlasAlert creates a measurer.Alert by extracting
+//the labels of a mongodbatlas.Alert.
+func AlertFromMongodbAtlasAlert(a *mongodbatlas.Alert) *Alert {
+	return &Alert{
+		AlertConfigID:   a.AlertConfigID,
+		EventTypeName:   a.EventTypeName,
+		Status:          a.Status,
+		ClusterName:     a.ClusterName,
+		ReplicaSetName:  a.ReplicaSetName,
+		HostnameAndPort: a.HostnameAndPort,
+		MetricName:      a.MetricName,
+	}
+}
@@ -0,0 +1,83 @@
+package measurer
+
+import (
+	"time"
+
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+//SnapshotStatuses are the statuses of a Cloud Provider Snapshot.
+var SnapshotStatuses = []string{"queued", "inProgress", "completed", "failed"}
+
+//RestoreJobStates are the states of a restore job.
+//The API has no state field, the state is derived from the job's flags and finish time.
+var RestoreJobStates = []string{"in_progress", "finished", "cancelled", "expired"}
+
+// Backup summarizes the Cloud Provider Snapshots and restore jobs of one cluster.
+type Backup struct {
+	ClusterName        string
+	Snapshots          int
+	SnapshotsSizeBytes float64
+	//LastSnapshotStatus is the status of the most recently created snapshot, empty without snapshots.
+	LastSnapshotStatus string
+	//LastSuccessfulSnapshot is zero if no snapshot completed yet.
+	LastSuccessfulSnapshot          time.Time
+	LastSuccessfulSnapshotSizeBytes float64
+	//RestoreJobs counts the restore jobs by state.
+	RestoreJobs map[string]int
+}
+
+func (b *Backup) PromVariableLabelNames() []string {
+	return []string{"cluster_name"}
+}
+
+func (b *Backup) PromVariableLabelValues() []string {
+	return []string{b.ClusterName}
+}
+
+//BackupFromMongodbAtlasSnapshots creates a measurer.Backup from the snapshots and restore jobs of a cluster.
+//Snapshots with an invalid creation time are counted but can not be the most recent one.
+func BackupFromMongodbAtlasSnapshots(clusterName string, snapshots []*mongodbatlas.CloudProviderSnapshot, restoreJobs []*mongodbatlas.CloudProviderSnapshotRestoreJob) *Backup {
+	backup := &Backup{
+		ClusterName: clusterName,
+		Snapshots:   len(snapshots),
+		RestoreJobs: make(map[string]int, len(RestoreJobStates)),
+	}
+
+	var lastCreated time.Time
+	for _, snapshot := range snapshots {
+		backup.SnapshotsSizeBytes += float64(snapshot.StorageSizeBytes)
+
+		created, err := time.Parse(time.RFC3339, snapshot.CreatedAt)
+		if err != nil {
+			continue
+		}
+		if created.After(lastCreated) {
+			lastCreated = created
+			backup.LastSnapshotStatus = snapshot.Status
+		}
+		if snapshot.Status == "completed" && created.After(backup.LastSuccessfulSnapshot) {
+			backup.LastSuccessfulSnapshot = created
+			backup.LastSuccessfulSnapshotSizeBytes = float64(snapshot.StorageSizeBytes)
+		}
+	}
+
+	for _, job := range restoreJobs {
+		backup.RestoreJobs[RestoreJobState(job)]++
+	}
+	return backup
+}
+
+//RestoreJobState returns one of RestoreJobStates.
+func RestoreJobState(job *mongodbatlas.CloudProviderSnapshotRestoreJob) string {
+	switch {
+	case job.Cancelled:
+		return "cancelled"
+	case job.Expired:
+		return "expired"
+	case job.FinishedAt != "":
+		return "finished"
+	default:
+		return "in_progress"
+	}
+}
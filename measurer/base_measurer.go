@@ -1,9 +1,11 @@
 package measurer
 
 import (
+	"errors"
 	"fmt"
 	"mongodbatlas_exporter/collector/transformer"
 	"mongodbatlas_exporter/model"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.mongodb.org/atlas/mongodbatlas"
@@ -75,31 +77,125 @@ func (b *Base) PromConstLabels() prometheus.Labels {
 
 //metadataToMetric transforms the measurement metadata we received from Atlas into a
 //prometheus compatible metric description.
-func metadataToMetric(metadata *model.MeasurementMetadata, namespace, collectorPrefix, defaultHelp string, variableLabels []string, constLabels prometheus.Labels) (*PromMetric, error) {
-	promName, err := transformer.TransformName(metadata)
-	if err != nil {
+//Known measurements take their help and type from the catalogue, others use defaultHelp.
+//A mapping of the rules can rename the metric, override its help and type and add labels.
+func metadataToMetric(metadata *model.MeasurementMetadata, namespace, collectorPrefix, defaultHelp string, variableLabels []string, constLabels prometheus.Labels, rules Rules) (*PromMetric, error) {
+	mapped, isMapped := rules.MapMeasurement(metadata.Name)
+	nameMetadata := metadata
+	if isMapped {
+		nameMetadata = &model.MeasurementMetadata{Name: mapped.Name, Units: metadata.Units}
+	}
+
+	promName, err := transformer.TransformName(nameMetadata)
+	//units Atlas introduced after this release keep their values and name them in a label,
+	//so the measurement is still exported, unless the rules know how to convert them.
+	unknownUnit := errors.Is(err, transformer.ErrUnknownUnit)
+	var multiplier float64
+	if unitRule, ok := rules.UnitRule(metadata.Units); ok && unknownUnit {
+		unknownUnit = false
+		promName = strings.ToLower(nameMetadata.Name) + unitRule.Suffix
+		multiplier = unitRule.Multiplier
+	} else if unknownUnit {
+		promName = transformer.TransformRawUnitName(nameMetadata)
+	} else if err != nil {
 		msg := "can't transform measurement Name (%s) into metric name"
 		return nil, fmt.Errorf(msg, metadata.Name)
 	}
+	counterName, isRate := transformer.TransformCounterName(nameMetadata)
+	if isMapped && mapped.FullName {
+		//the name of the mapping is the whole name, the counter of a rate appends _total to it.
+		collectorPrefix = ""
+		promName = strings.ToLower(mapped.Name)
+		counterName = promName + "_total"
+	}
 	promType, err := transformer.TransformType(metadata)
 	if err != nil {
 		msg := "can't transform measurement Units (%s) into prometheus.ValueType"
 		return nil, fmt.Errorf(msg, metadata.Units)
 	}
 
+	help := "Original measurements.name: '" + metadata.Name + "'. " + defaultHelp
+	if entry, ok := LookupCatalogue(metadata.Name); ok && !isMapped {
+		help = "Original measurements.name: '" + metadata.Name + "'. " + entry.Help
+		//the recommended type only holds for the units the entry was written for.
+		if entry.Units == metadata.Units && entry.Type != "" {
+			promType = valueTypes[entry.Type]
+		}
+	}
+	if isMapped {
+		//every measurement of the mapping shares the metric, so the help can not name a single one.
+		help = "Original measurements.name matching '" + mapped.Pattern + "'. " + defaultHelp
+		if mapped.Help != "" {
+			help = mapped.Help
+		}
+		//a rate is no counter, its integral is, see TransformCounterName.
+		if mapped.Type == "counter" && isRate {
+			return nil, fmt.Errorf("mapping %s sets type counter on the rate %s, enable the rate counters instead", mapped.Pattern, metadata.Name)
+		}
+		if mapped.Type != "" {
+			promType = valueTypes[mapped.Type]
+		}
+		if constLabels, err = addConstLabels(constLabels, mapped.Labels); err != nil {
+			return nil, fmt.Errorf("mapping of measurement %s: %w", metadata.Name, err)
+		}
+	}
+	if unknownUnit {
+		if constLabels, err = addConstLabels(constLabels, map[string]string{"unit": string(metadata.Units)}); err != nil {
+			return nil, fmt.Errorf("measurement %s with unknown unit: %w", metadata.Name, err)
+		}
+	}
+
+	fqName := prometheus.BuildFQName(namespace, collectorPrefix, promName)
+	newAggregateDesc := func(aggregate string) *prometheus.Desc {
+		return prometheus.NewDesc(fqName+"_"+aggregate, "The "+aggregate+" of the datapoints of the period. "+help, variableLabels, constLabels)
+	}
+
 	metric := PromMetric{
-		Type: promType,
-		Desc: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, collectorPrefix, promName),
-			"Original measurements.name: '"+metadata.Name+"'. "+defaultHelp,
-			variableLabels, constLabels,
-		),
-		Metadata: metadata,
+		Type:        promType,
+		FQName:      fqName,
+		Help:        help,
+		ConstLabels: constLabels,
+		Desc:        prometheus.NewDesc(fqName, help, variableLabels, constLabels),
+		Min:         newAggregateDesc("min"),
+		Max:         newAggregateDesc("max"),
+		Avg:         newAggregateDesc("avg"),
+		Metadata:    metadata,
+		UnknownUnit: unknownUnit,
+		Multiplier:  multiplier,
+	}
+	if isRate {
+		metric.Counter = prometheus.NewDesc(prometheus.BuildFQName(namespace, collectorPrefix, counterName),
+			"The rate integrated over its datapoints, reset when the process restarts. "+help, variableLabels, constLabels)
 	}
 
 	return &metric, nil
 }
 
+//addConstLabels returns constLabels with the extra labels, which must not exist yet.
+func addConstLabels(constLabels prometheus.Labels, extra map[string]string) (prometheus.Labels, error) {
+	if len(extra) == 0 {
+		return constLabels, nil
+	}
+	labels := make(prometheus.Labels, len(constLabels)+len(extra))
+	for name, value := range constLabels {
+		labels[name] = value
+	}
+	for name, value := range extra {
+		if _, ok := labels[name]; ok {
+			return nil, fmt.Errorf("sets the existing label %s", name)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+//valueTypes are the Prometheus types a mapping or the catalogue can set.
+var valueTypes = map[string]prometheus.ValueType{
+	"gauge":   prometheus.GaugeValue,
+	"counter": prometheus.CounterValue,
+	"untyped": prometheus.UntypedValue,
+}
+
 //baseFromMongodbAtlasProcess populates the base fields for both disk
 //and process measurers.
 func baseFromMongodbAtlasProcess(p *mongodbatlas.Process) *Base {
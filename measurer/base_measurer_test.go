@@ -1,6 +1,13 @@
 package measurer
 
-import "testing"
+import (
+	"mongodbatlas_exporter/config"
+	"mongodbatlas_exporter/model"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
 
 func TestConstLabels(t *testing.T) {
 	b := Base{
@@ -34,3 +41,130 @@ func TestConstLabels(t *testing.T) {
 		}
 	}
 }
+
+//testRules maps the measurements with the configuration and allows every metric.
+type testRules struct {
+	config.Config
+}
+
+func (r *testRules) AllowedMetricName(string) bool {
+	return true
+}
+
+func (r *testRules) UnitRule(unit model.UnitEnum) (config.UnitRule, bool) {
+	rule, ok := r.Units[string(unit)]
+	return rule, ok
+}
+
+// TestBuildPromMetrics_mapping checks that a mapping can merge several measurements
+// into one metric distinguished by a label.
+func TestBuildPromMetrics_mapping(t *testing.T) {
+	assert := assert.New(t)
+	match, err := config.NewRegexp("OPCOUNTER_(?P<op>.*)")
+	assert.NoError(err)
+	rules := &testRules{config.Config{Mappings: []config.Mapping{{
+		Match:  match,
+		Name:   "opcounters",
+		Labels: map[string]string{"op": "${op}"},
+		Type:   "untyped",
+	}}}}
+
+	process := &Process{Base: Base{ProjectID: "p", RsName: "rs", UserAlias: "host:27017"}}
+	process.Metadata = map[model.MeasurementID]*model.MeasurementMetadata{}
+	for _, name := range []string{"OPCOUNTER_INSERT", "OPCOUNTER_DELETE", "CONNECTIONS"} {
+		metadata := &model.MeasurementMetadata{Name: name, Units: model.SCALAR_PER_SECOND}
+		process.Metadata[metadata.ID()] = metadata
+	}
+
+	assert.NoError(BuildPromMetrics(process, "mongodbatlas", "processes_stats", rules))
+
+	metrics := make(map[string]*PromMetric)
+	for _, metric := range process.PromMetrics() {
+		metrics[metric.Metadata.Name] = metric
+	}
+	insert := metrics["OPCOUNTER_INSERT"]
+	assert.Equal("mongodbatlas_processes_stats_opcounters_ratio", insert.FQName)
+	assert.Equal(prometheus.UntypedValue, insert.Type)
+	assert.Equal("insert", insert.ConstLabels["op"])
+	assert.Equal("host:27017", insert.ConstLabels["user_alias"])
+	assert.Equal(insert.Help, metrics["OPCOUNTER_DELETE"].Help)
+	assert.Equal("delete", metrics["OPCOUNTER_DELETE"].ConstLabels["op"])
+	assert.Contains(insert.Counter.String(), `"mongodbatlas_processes_stats_opcounters_total"`)
+
+	assert.Equal("mongodbatlas_processes_stats_connections_ratio", metrics["CONNECTIONS"].FQName)
+	assert.Equal(prometheus.GaugeValue, metrics["CONNECTIONS"].Type)
+
+	//a full name replaces the prefix and the unit suffix.
+	rules.Mappings[0].FullName = true
+	assert.NoError(BuildPromMetrics(process, "mongodbatlas", "processes_stats", rules))
+	for _, metric := range process.PromMetrics() {
+		if metric.Metadata.Name == "OPCOUNTER_INSERT" {
+			assert.Equal("mongodbatlas_opcounters", metric.FQName)
+			assert.Contains(metric.Counter.String(), `"mongodbatlas_opcounters_total"`)
+		}
+	}
+
+	//a rate is no counter.
+	rules.Mappings[0].Type = "counter"
+	assert.Error(BuildPromMetrics(process, "mongodbatlas", "processes_stats", rules))
+	rules.Mappings[0].Type = ""
+
+	//measurements mapped to the same series are rejected.
+	rules.Mappings[0].Labels = nil
+	assert.Error(BuildPromMetrics(process, "mongodbatlas", "processes_stats", rules))
+
+	//a mapping can not override the labels of the measurer.
+	rules.Mappings[0].Labels = map[string]string{"rs_name": "$1"}
+	assert.Error(BuildPromMetrics(process, "mongodbatlas", "processes_stats", rules))
+}
+
+// TestBuildPromMetrics_unknownUnit checks that a measurement of an unknown unit
+// is exported with the raw unit instead of failing the measurer.
+func TestBuildPromMetrics_unknownUnit(t *testing.T) {
+	assert := assert.New(t)
+	process := &Process{Base: Base{ProjectID: "p", RsName: "rs", UserAlias: "host:27017"}}
+	metadata := &model.MeasurementMetadata{Name: "NEW_MEASUREMENT", Units: "WIDGETS_PER_SECOND"}
+	process.Metadata = map[model.MeasurementID]*model.MeasurementMetadata{metadata.ID(): metadata}
+
+	assert.NoError(BuildPromMetrics(process, "mongodbatlas", "processes_stats", &testRules{}))
+
+	assert.Len(process.PromMetrics(), 1)
+	metric := process.PromMetrics()[0]
+	assert.True(metric.UnknownUnit)
+	assert.Equal("mongodbatlas_processes_stats_new_measurement_widgets_per_second", metric.FQName)
+	assert.Equal("WIDGETS_PER_SECOND", metric.ConstLabels["unit"])
+	assert.Equal("host:27017", metric.ConstLabels["user_alias"])
+	assert.Nil(metric.Counter)
+}
+
+// TestBuildPromMetrics_unitRule checks that the configured rule of an unknown unit names and scales the metric.
+func TestBuildPromMetrics_unitRule(t *testing.T) {
+	assert := assert.New(t)
+	process := &Process{Base: Base{ProjectID: "p", RsName: "rs", UserAlias: "host:27017"}}
+	kibibytes := &model.MeasurementMetadata{Name: "NEW_MEASUREMENT", Units: "KIBIBYTES"}
+	bytes := &model.MeasurementMetadata{Name: "SYSTEM_MEMORY_USED", Units: model.BYTES}
+	process.Metadata = map[model.MeasurementID]*model.MeasurementMetadata{kibibytes.ID(): kibibytes, bytes.ID(): bytes}
+	rules := &testRules{config.Config{Units: map[string]config.UnitRule{
+		"KIBIBYTES": {Suffix: "_bytes", Multiplier: 1024},
+		//built-in units keep their own rules.
+		string(model.BYTES): {Suffix: "_kilobytes", Multiplier: 0.001},
+	}}}
+
+	assert.NoError(BuildPromMetrics(process, "mongodbatlas", "processes_stats", rules))
+
+	metrics := make(map[string]*PromMetric)
+	for _, metric := range process.PromMetrics() {
+		metrics[metric.FQName] = metric
+	}
+	assert.Len(metrics, 2)
+	metric := metrics["mongodbatlas_processes_stats_new_measurement_bytes"]
+	if assert.NotNil(metric) {
+		assert.False(metric.UnknownUnit)
+		assert.NotContains(metric.ConstLabels, "unit")
+		assert.Equal(2048.0, metric.Scale(2))
+	}
+	metric = metrics["mongodbatlas_processes_stats_system_memory_used_bytes"]
+	if assert.NotNil(metric) {
+		assert.Equal(2.0, metric.Scale(2))
+	}
+}
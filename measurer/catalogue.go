@@ -0,0 +1,44 @@
+package measurer
+
+import (
+	_ "embed"
+	"mongodbatlas_exporter/model"
+
+	"gopkg.in/yaml.v2"
+)
+
+//go:embed catalogue.yml
+var catalogueFile []byte
+
+// CatalogueEntry describes a known Atlas measurement.
+type CatalogueEntry struct {
+	Help  string         `yaml:"help"`
+	Units model.UnitEnum `yaml:"units"`
+	//Type is the recommended Prometheus type of the metric: gauge, counter or untyped.
+	Type string `yaml:"type"`
+}
+
+type catalogue struct {
+	Version      string                    `yaml:"version"`
+	Measurements map[string]CatalogueEntry `yaml:"measurements"`
+}
+
+//helpCatalogue is parsed once, an invalid embedded file is a bug of the release.
+var helpCatalogue = func() catalogue {
+	var c catalogue
+	if err := yaml.UnmarshalStrict(catalogueFile, &c); err != nil {
+		panic("invalid measurement catalogue: " + err.Error())
+	}
+	return c
+}()
+
+// CatalogueVersion is the version of the embedded measurement catalogue.
+func CatalogueVersion() string {
+	return helpCatalogue.Version
+}
+
+// LookupCatalogue returns the catalogue entry of an Atlas measurement name, ok is false for unknown names.
+func LookupCatalogue(name string) (entry CatalogueEntry, ok bool) {
+	entry, ok = helpCatalogue.Measurements[name]
+	return entry, ok
+}
@@ -0,0 +1,42 @@
+package measurer
+
+import (
+	"mongodbatlas_exporter/collector/transformer"
+	"mongodbatlas_exporter/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCatalogue checks that every entry of the embedded catalogue can be turned into a metric.
+func TestCatalogue(t *testing.T) {
+	assert := assert.New(t)
+	assert.NotEmpty(CatalogueVersion())
+	assert.NotEmpty(helpCatalogue.Measurements)
+
+	for name, entry := range helpCatalogue.Measurements {
+		assert.NotEmpty(entry.Help, name)
+		assert.Contains(valueTypes, entry.Type, name)
+		_, err := transformer.TransformName(&model.MeasurementMetadata{Name: name, Units: entry.Units})
+		assert.NoError(err, name)
+	}
+}
+
+func TestBuildPromMetrics_catalogue(t *testing.T) {
+	assert := assert.New(t)
+	process := &Process{Base: Base{ProjectID: "p", RsName: "rs", UserAlias: "host:27017"}}
+	process.Metadata = map[model.MeasurementID]*model.MeasurementMetadata{}
+	for _, name := range []string{"CONNECTIONS", "UNKNOWN_MEASUREMENT"} {
+		metadata := &model.MeasurementMetadata{Name: name, Units: model.SCALAR}
+		process.Metadata[metadata.ID()] = metadata
+	}
+
+	assert.NoError(BuildPromMetrics(process, "mongodbatlas", "processes_stats", &testRules{}))
+
+	helps := make(map[string]string)
+	for _, metric := range process.PromMetrics() {
+		helps[metric.Metadata.Name] = metric.Help
+	}
+	assert.Equal("Original measurements.name: 'CONNECTIONS'. Open client connections.", helps["CONNECTIONS"])
+	assert.Equal("Original measurements.name: 'UNKNOWN_MEASUREMENT'. "+DEFAULT_HELP, helps["UNKNOWN_MEASUREMENT"])
+}
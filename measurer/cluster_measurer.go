@@ -0,0 +1,128 @@
+package measurer
+
+import (
+	"math"
+
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+//ClusterStates are the states an Atlas cluster can be in.
+//Every state is reported so alerts can match on a particular state.
+var ClusterStates = []string{"IDLE", "CREATING", "UPDATING", "DELETING", "DELETED", "REPAIRING"}
+
+// Cluster contains the configuration and state of one cluster.
+// Unlike processes and disks clusters do not have measurements,
+// all values are taken from the Clusters API.
+type Cluster struct {
+	ProjectID string
+	Name      string
+	//ClusterType is REPLICASET, SHARDED or GEOSHARDED.
+	ClusterType    string
+	StateName      string
+	MongoDBVersion string
+	InstanceSize   string
+	ProviderName   string
+	RegionName     string
+	Paused         bool
+	BackupEnabled  bool
+	//ProviderBackupEnabled is true if the cluster takes Cloud Provider Snapshots.
+	ProviderBackupEnabled bool
+	DiskSizeBytes         float64
+	//NumShards is 1 for replica sets.
+	NumShards         int64
+	ReplicationFactor int64
+	//Auto scaling bounds of the instance size.
+	AutoScalingComputeEnabled bool
+	AutoScalingDiskEnabled    bool
+	MinInstanceSize           string
+	MaxInstanceSize           string
+}
+
+//PromVariableLabelNames clusters share one collector per project
+//so the cluster name is a variable label.
+func (c *Cluster) PromVariableLabelNames() []string {
+	return []string{"cluster_name"}
+}
+
+func (c *Cluster) PromVariableLabelValues() []string {
+	return []string{c.Name}
+}
+
+//PromInfoLabelNames are the labels of the cluster info metric.
+//They change rarely so they do not add much cardinality.
+func (c *Cluster) PromInfoLabelNames() []string {
+	return append(c.PromVariableLabelNames(), "cluster_type", "instance_size", "provider", "region", "mongodb_version")
+}
+
+func (c *Cluster) PromInfoLabelValues() []string {
+	return append(c.PromVariableLabelValues(), c.ClusterType, c.InstanceSize, c.ProviderName, c.RegionName, c.MongoDBVersion)
+}
+
+func (c *Cluster) PromAutoScalingLabelNames() []string {
+	return append(c.PromVariableLabelNames(), "min_instance_size", "max_instance_size")
+}
+
+func (c *Cluster) PromAutoScalingLabelValues() []string {
+	return append(c.PromVariableLabelValues(), c.MinInstanceSize, c.MaxInstanceSize)
+}
+
+//AutoScalingAtMax reports whether compute auto scaling can not scale up any further.
+func (c *Cluster) AutoScalingAtMax() bool {
+	return c.AutoScalingComputeEnabled && c.MaxInstanceSize != "" && c.InstanceSize == c.MaxInstanceSize
+}
+
+//ClusterFromMongodbAtlasCluster creates a measurer.Cluster by extracting
+//the reported fields from a mongodbatlas.Cluster.
+func ClusterFromMongodbAtlasCluster(c *mongodbatlas.Cluster) *Cluster {
+	cluster := &Cluster{
+		ProjectID:      c.GroupID,
+		Name:           c.Name,
+		ClusterType:    c.ClusterType,
+		StateName:      c.StateName,
+		MongoDBVersion: c.MongoDBVersion,
+		Paused:         boolValue(c.Paused),
+		//BackupEnabled is the legacy backup, both count as backup.
+		BackupEnabled:         boolValue(c.ProviderBackupEnabled) || boolValue(c.BackupEnabled),
+		ProviderBackupEnabled: boolValue(c.ProviderBackupEnabled),
+		NumShards:             int64Value(c.NumShards),
+		ReplicationFactor:     int64Value(c.ReplicationFactor),
+	}
+
+	if c.DiskSizeGB != nil {
+		cluster.DiskSizeBytes = *c.DiskSizeGB * math.Pow(1024, 3)
+	}
+
+	if c.AutoScaling != nil {
+		cluster.AutoScalingDiskEnabled = boolValue(c.AutoScaling.DiskGBEnabled)
+		if c.AutoScaling.Compute != nil {
+			cluster.AutoScalingComputeEnabled = boolValue(c.AutoScaling.Compute.Enabled)
+		}
+	}
+
+	if settings := c.ProviderSettings; settings != nil {
+		cluster.InstanceSize = settings.InstanceSizeName
+		cluster.ProviderName = settings.ProviderName
+		cluster.RegionName = settings.RegionName
+		//shared tier clusters are hosted on a backing provider.
+		if settings.BackingProviderName != "" {
+			cluster.ProviderName = settings.BackingProviderName
+		}
+		if settings.AutoScaling != nil && settings.AutoScaling.Compute != nil {
+			cluster.MinInstanceSize = settings.AutoScaling.Compute.MinInstanceSize
+			cluster.MaxInstanceSize = settings.AutoScaling.Compute.MaxInstanceSize
+		}
+	}
+
+	return cluster
+}
+
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
+func int64Value(i *int64) int64 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
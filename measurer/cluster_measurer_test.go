@@ -0,0 +1,49 @@
+package measurer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// TestClusterFromMongodbAtlasCluster examines the conversion of a
+// mongodbatlas.Cluster (result from the Atlas API) into a measurer.Cluster.
+func TestClusterFromMongodbAtlasCluster(t *testing.T) {
+	enabled := true
+	diskSizeGB := float64(2)
+	cluster := mongodbatlas.Cluster{
+		GroupID:       "9uf201u9ur1",
+		Name:          "cluster0",
+		StateName:     "IDLE",
+		BackupEnabled: &enabled,
+		DiskSizeGB:    &diskSizeGB,
+		AutoScaling: &mongodbatlas.AutoScaling{
+			Compute: &mongodbatlas.Compute{Enabled: &enabled},
+		},
+		ProviderSettings: &mongodbatlas.ProviderSettings{
+			ProviderName:        "TENANT",
+			BackingProviderName: "GCP",
+			InstanceSizeName:    "M10",
+			AutoScaling: &mongodbatlas.AutoScaling{
+				Compute: &mongodbatlas.Compute{MinInstanceSize: "M10", MaxInstanceSize: "M40"},
+			},
+		},
+	}
+
+	clusterMeasurer := ClusterFromMongodbAtlasCluster(&cluster)
+
+	//legacy backup counts as backup.
+	assert.True(t, clusterMeasurer.BackupEnabled)
+	//missing pointers are zero values.
+	assert.False(t, clusterMeasurer.Paused)
+	assert.Equal(t, int64(0), clusterMeasurer.NumShards)
+	assert.Equal(t, float64(2*1024*1024*1024), clusterMeasurer.DiskSizeBytes)
+	//shared tier clusters report the backing provider.
+	assert.Equal(t, "GCP", clusterMeasurer.ProviderName)
+	assert.Equal(t, "M40", clusterMeasurer.MaxInstanceSize)
+	assert.False(t, clusterMeasurer.AutoScalingAtMax())
+
+	clusterMeasurer.InstanceSize = "M40"
+	assert.True(t, clusterMeasurer.AutoScalingAtMax())
+}
@@ -0,0 +1,25 @@
+package measurer
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+// Database contains all measurements of one database of a process
+type Database struct {
+	Base
+	DatabaseName string
+}
+
+func (d *Database) PromConstLabels() prometheus.Labels {
+	labels := d.Base.PromConstLabels()
+	labels["database_name"] = d.DatabaseName
+	return labels
+}
+
+func DatabaseFromMongodbAtlasProcessDatabase(p *mongodbatlas.Process, d *mongodbatlas.ProcessDatabase) *Database {
+	return &Database{
+		Base:         *baseFromMongodbAtlasProcess(p),
+		DatabaseName: d.DatabaseName,
+	}
+}
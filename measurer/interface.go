@@ -1,15 +1,56 @@
 package measurer
 
 import (
+	"fmt"
+	"mongodbatlas_exporter/config"
 	"mongodbatlas_exporter/model"
+	"sort"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type PromMetric struct {
-	Type     prometheus.ValueType
-	Desc     *prometheus.Desc
+	Type prometheus.ValueType
+	//FQName, Help and ConstLabels are the ones of Desc.
+	FQName      string
+	Help        string
+	ConstLabels prometheus.Labels
+	Desc        *prometheus.Desc
+	//Min, Max and Avg describe the gauges aggregating the datapoints of the fetched period.
+	Min, Max, Avg *prometheus.Desc
+	//Counter describes the counter integrating a rate, it is nil for measurements that are not rates.
+	Counter  *prometheus.Desc
 	Metadata *model.MeasurementMetadata
+	//UnknownUnit is set if the unit of the measurement is unknown, its name then ends in the raw unit
+	//and its values are not converted.
+	UnknownUnit bool
+	//Multiplier converts the values of a measurement of a configured unit, see Scale.
+	Multiplier float64
+}
+
+//Scale converts a value of the measurement with the multiplier of its configured unit.
+//The transformer converts the values of the built-in units, so they are returned as they are.
+func (x *PromMetric) Scale(value float64) float64 {
+	if x.Multiplier == 0 {
+		return value
+	}
+	return value * x.Multiplier
+}
+
+//seriesKey identifies the series of the metric by its name and constant labels, e.g. name{a="b",c="d"}.
+func (x *PromMetric) seriesKey() string {
+	names := make([]string, 0, len(x.ConstLabels))
+	for name := range x.ConstLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	labels := make([]string, len(names))
+	for i, name := range names {
+		labels[i] = fmt.Sprintf("%s=%q", name, x.ConstLabels[name])
+	}
+	return x.FQName + "{" + strings.Join(labels, ",") + "}"
 }
 
 //ErrorLabels consumes prometheus.Labels and adds more labels to the map.
@@ -55,18 +96,51 @@ type Measurer interface {
 //It works better without a caller so that the PromVariableLabelNames and PromConstLabels are
 //correctly tied to the measurer. Otherwise this function would need to be redeclared exactly
 //for each measurer.
-func BuildPromMetrics(m Measurer, namespace, collectorPrefix string) error {
-	promMetrics := make([]*PromMetric, len(m.GetMetaData()))
+//Metrics whose fully qualified name is not allowed are left out, so they never become series.
+//Measurements the rules map to the same series are rejected, they would fail every scrape.
+func BuildPromMetrics(m Measurer, namespace, collectorPrefix string, rules Rules) error {
+	promMetrics := make([]*PromMetric, 0, len(m.GetMetaData()))
+	//series holds the measurement of every series by its name and labels.
+	series := make(map[string]string, len(m.GetMetaData()))
 
-	i := 0
 	for _, metadata := range m.GetMetaData() {
-		metric, err := metadataToMetric(metadata, namespace, collectorPrefix, DEFAULT_HELP, m.PromVariableLabelNames(), m.PromConstLabels())
+		metric, err := NewPromMetric(m, metadata, namespace, collectorPrefix, rules)
 		if err != nil {
 			return err
 		}
-		promMetrics[i] = metric
-		i++
+		if metric == nil {
+			continue
+		}
+		key := metric.seriesKey()
+		if other, ok := series[key]; ok {
+			return fmt.Errorf("measurements %s and %s are both mapped to the series %s", other, metadata.Name, key)
+		}
+		series[key] = metadata.Name
+		promMetrics = append(promMetrics, metric)
 	}
 	m.setPromMetrics(promMetrics)
 	return nil
 }
+
+// Rules customize how measurements become metrics, the Atlas client implements them with its configuration.
+type Rules interface {
+	//AllowedMetricName reports whether a metric with the fully qualified name is exported.
+	AllowedMetricName(fqName string) bool
+	//MapMeasurement returns the mapping of an Atlas measurement name, ok is false if there is none.
+	MapMeasurement(name string) (mapped config.MappedMeasurement, ok bool)
+	//UnitRule returns the rule of a unit the exporter does not know, ok is false if there is none.
+	UnitRule(unit model.UnitEnum) (rule config.UnitRule, ok bool)
+}
+
+// NewPromMetric builds the metric of a single measurement of the measurer.
+// It returns nil if the rules do not allow the metric.
+func NewPromMetric(m Measurer, metadata *model.MeasurementMetadata, namespace, collectorPrefix string, rules Rules) (*PromMetric, error) {
+	metric, err := metadataToMetric(metadata, namespace, collectorPrefix, DEFAULT_HELP, m.PromVariableLabelNames(), m.PromConstLabels(), rules)
+	if err != nil {
+		return nil, err
+	}
+	if !rules.AllowedMetricName(metric.FQName) {
+		return nil, nil
+	}
+	return metric, nil
+}
@@ -0,0 +1,63 @@
+package measurer
+
+import (
+	"regexp"
+	"strconv"
+
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+var (
+	//slow query log lines are structured JSON since MongoDB 4.4 and plain text before.
+	jsonDurationRegexp   = regexp.MustCompile(`"durationMillis":\s*(\d+)`)
+	legacyDurationRegexp = regexp.MustCompile(`\s(\d+)ms\s*$`)
+)
+
+// PerformanceAdvisor summarizes the Performance Advisor results of one process by namespace,
+// a namespace being <database>.<collection>.
+type PerformanceAdvisor struct {
+	SuggestedIndexes map[string]int
+	SlowQueries      map[string]int
+	//SlowQueriesDurationSeconds is the total duration of the slow queries.
+	//Log lines without a duration are counted but do not add to it.
+	SlowQueriesDurationSeconds map[string]float64
+}
+
+func (p *PerformanceAdvisor) PromVariableLabelNames() []string {
+	return []string{"namespace"}
+}
+
+//PerformanceAdvisorFromMongodbAtlas creates a measurer.PerformanceAdvisor by counting
+//the suggested indexes and slow queries of every namespace.
+func PerformanceAdvisorFromMongodbAtlas(indexes []*mongodbatlas.SuggestedIndex, queries []*mongodbatlas.SlowQuery) *PerformanceAdvisor {
+	advisor := &PerformanceAdvisor{
+		SuggestedIndexes:           make(map[string]int),
+		SlowQueries:                make(map[string]int),
+		SlowQueriesDurationSeconds: make(map[string]float64),
+	}
+
+	for _, index := range indexes {
+		advisor.SuggestedIndexes[index.Namespace]++
+	}
+
+	for _, query := range queries {
+		advisor.SlowQueries[query.Namespace]++
+		if millis, ok := slowQueryDurationMillis(query.Line); ok {
+			advisor.SlowQueriesDurationSeconds[query.Namespace] += millis / 1000
+		}
+	}
+	return advisor
+}
+
+func slowQueryDurationMillis(line string) (float64, bool) {
+	match := jsonDurationRegexp.FindStringSubmatch(line)
+	if match == nil {
+		match = legacyDurationRegexp.FindStringSubmatch(line)
+	}
+	if match == nil {
+		return 0, false
+	}
+
+	millis, err := strconv.ParseFloat(match[1], 64)
+	return millis, err == nil
+}
@@ -0,0 +1,27 @@
+package measurer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+func TestPerformanceAdvisorFromMongodbAtlas(t *testing.T) {
+	indexes := []*mongodbatlas.SuggestedIndex{
+		{Namespace: "shop.orders"},
+		{Namespace: "shop.orders"},
+		{Namespace: "shop.users"},
+	}
+	queries := []*mongodbatlas.SlowQuery{
+		{Namespace: "shop.orders", Line: `{"t":{"$date":"2021-03-01T10:00:00.000+00:00"},"s":"I","c":"COMMAND","msg":"Slow query","attr":{"ns":"shop.orders","durationMillis":250}}`},
+		{Namespace: "shop.orders", Line: `2021-03-01T10:00:00.000+0000 I COMMAND [conn1] command shop.orders command: find { find: "orders" } planSummary: COLLSCAN 1500ms`},
+		{Namespace: "shop.users", Line: "unparseable"},
+	}
+
+	advisor := PerformanceAdvisorFromMongodbAtlas(indexes, queries)
+
+	assert.Equal(t, map[string]int{"shop.orders": 2, "shop.users": 1}, advisor.SuggestedIndexes)
+	assert.Equal(t, map[string]int{"shop.orders": 2, "shop.users": 1}, advisor.SlowQueries)
+	assert.Equal(t, map[string]float64{"shop.orders": 1.75}, advisor.SlowQueriesDurationSeconds)
+}
@@ -8,9 +8,10 @@ import (
 // Process contains all measurements of one Process
 type Process struct {
 	Base
-	Disks   []*Disk
-	Version string
-	Port    int
+	Disks     []*Disk
+	Databases []*Database
+	Version   string
+	Port      int
 }
 
 func (p *Process) PromInfoConstLabels() prometheus.Labels {
@@ -0,0 +1,94 @@
+package mongodbatlas
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	//circuitFailureThreshold is the number of consecutive failed requests that open the circuit of a project.
+	circuitFailureThreshold = 5
+	//circuitOpenDuration is how long an open circuit rejects requests before letting them through again.
+	circuitOpenDuration = time.Minute
+)
+
+// ErrCircuitOpen is returned for requests to a project whose circuit is open.
+var ErrCircuitOpen = errors.New("circuit open, too many failed Atlas API requests for the project")
+
+var circuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "mongodbatlas_api_circuit_open",
+	Help: "1 if requests for the project are rejected because the Atlas API failed persistently, 0 otherwise.",
+}, []string{"project_id"})
+
+func init() {
+	prometheus.MustRegister(circuitOpen)
+}
+
+// circuitBreaker stops sending requests for a project after repeated failures,
+// so an overloaded or rate limiting API is not hammered by every scrape.
+// After circuitOpenDuration requests are let through again, the first result
+// decides whether the circuit closes or stays open.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{circuits: make(map[string]*circuit)}
+}
+
+// allow returns ErrCircuitOpen if requests for the project are rejected.
+func (b *circuitBreaker) allow(projectID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[projectID]
+	if ok && time.Now().Before(c.openUntil) {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// success closes the circuit of the project.
+func (b *circuitBreaker) success(projectID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.circuits[projectID]; ok {
+		delete(b.circuits, projectID)
+		circuitOpen.WithLabelValues(projectID).Set(0)
+	}
+}
+
+// failure records a failed request. retryAfter extends the time the circuit stays open
+// if Atlas asked to wait longer than circuitOpenDuration.
+func (b *circuitBreaker) failure(projectID string, retryAfter time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[projectID]
+	if !ok {
+		c = &circuit{}
+		b.circuits[projectID] = c
+	}
+	c.failures++
+	//a half open circuit, i.e. one whose open duration is over, opens again on the first failure.
+	if c.failures < circuitFailureThreshold && c.openUntil.IsZero() {
+		return
+	}
+
+	openDuration := circuitOpenDuration
+	if retryAfter > openDuration {
+		openDuration = retryAfter
+	}
+	c.openUntil = time.Now().Add(openDuration)
+	circuitOpen.WithLabelValues(projectID).Set(1)
+}
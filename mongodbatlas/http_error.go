@@ -7,7 +7,11 @@ package mongodbatlas
 * up to a prometheus collector to report the number of errors.
  */
 
-import "fmt"
+import (
+	"fmt"
+
+	"go.mongodb.org/atlas/mongodbatlas"
+)
 
 type HTTPError struct {
 	StatusCode int
@@ -24,3 +28,15 @@ func (e *HTTPError) Error() string {
 	}
 	return e.Err.Error()
 }
+
+// newHTTPError wraps err together with the status code of r.
+// r is nil when the request did not get a response at all, e.g. on network errors.
+func newHTTPError(r *mongodbatlas.Response, err error) *HTTPError {
+	httpErr := &HTTPError{
+		Err: err,
+	}
+	if r != nil && r.Response != nil {
+		httpErr.StatusCode = r.StatusCode
+	}
+	return httpErr
+}
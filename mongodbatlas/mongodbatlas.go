@@ -3,9 +3,12 @@ package mongodbatlas
 import (
 	"context"
 	"errors"
+	"mongodbatlas_exporter/config"
 	"mongodbatlas_exporter/measurer"
 	m "mongodbatlas_exporter/model"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -16,34 +19,88 @@ import (
 
 const (
 	TYPE_MONGOS = "SHARD_MONGOS"
+	//maxPageSize is the maximum page size the Atlas API accepts.
+	maxPageSize = 500
 )
 
-var opts = &mongodbatlas.ProcessMeasurementListOptions{
-	Granularity: "PT1M",
-	Period:      "PT2M",
-}
-
 // AtlasClient implements mongodbatlas.Client
 type AtlasClient struct {
+	//mongodbatlasClient uses the default credentials.
 	mongodbatlasClient *mongodbatlas.Client
-	projectID          string
-	atlasClusters      []string
-	logger             log.Logger
+	//projectClients holds the clients of projects that define their own credentials.
+	projectClients map[string]*mongodbatlas.Client
+	config         *config.Config
+	logger         log.Logger
 }
 
 // Client wraps mongodbatlas.Client
 type Client interface {
-	GetDiskMeasurements(*measurer.Process, *measurer.Disk) error
-	GetProcessMeasurements(measurer.Process) (map[m.MeasurementID]*m.Measurement, error)
-	GetDiskMeasurementsMetadata(*measurer.Process, *measurer.Disk) (map[m.MeasurementID]*m.MeasurementMetadata, error)
-	GetProcessMeasurementsMetadata(*measurer.Process) *HTTPError
-	ListProcesses() ([]*mongodbatlas.Process, *HTTPError)
-	ListDisks(*mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *HTTPError)
+	GetDiskMeasurements(context.Context, *measurer.Process, *measurer.Disk) error
+	GetProcessMeasurements(context.Context, measurer.Process) (map[m.MeasurementID]*m.Measurement, error)
+	GetDiskMeasurementsMetadata(context.Context, *measurer.Process, *measurer.Disk) (map[m.MeasurementID]*m.MeasurementMetadata, error)
+	GetProcessMeasurementsMetadata(context.Context, *measurer.Process) *HTTPError
+	ListProjects(context.Context) ([]string, *HTTPError)
+	ListProcesses(ctx context.Context, projectID string) ([]*mongodbatlas.Process, *HTTPError)
+	ListDisks(context.Context, *mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *HTTPError)
+	ListClusters(ctx context.Context, projectID string) ([]mongodbatlas.Cluster, *HTTPError)
+	ListAlerts(ctx context.Context, projectID, status string) ([]mongodbatlas.Alert, *HTTPError)
+	ListEvents(ctx context.Context, projectID string, minDate time.Time) ([]*mongodbatlas.Event, *HTTPError)
+	ListSnapshots(ctx context.Context, projectID, clusterName string) ([]*mongodbatlas.CloudProviderSnapshot, *HTTPError)
+	ListRestoreJobs(ctx context.Context, projectID, clusterName string) ([]*mongodbatlas.CloudProviderSnapshotRestoreJob, *HTTPError)
+	ListDatabases(context.Context, *mongodbatlas.Process) ([]*mongodbatlas.ProcessDatabase, *HTTPError)
+	GetDatabaseMeasurements(context.Context, *measurer.Process, *measurer.Database) error
+	GetDatabaseMeasurementsMetadata(context.Context, *measurer.Process, *measurer.Database) (map[m.MeasurementID]*m.MeasurementMetadata, error)
+	GetSuggestedIndexes(context.Context, *measurer.Process) ([]*mongodbatlas.SuggestedIndex, *HTTPError)
+	GetSlowQueries(context.Context, *measurer.Process) ([]*mongodbatlas.SlowQuery, *HTTPError)
+	measurer.Rules
 }
 
+//limiter and breaker are shared by the clients of every configuration, so a reload
+//keeps the budget in use and the open circuits of the projects.
+var (
+	limiter = newRateLimiter(func(string) config.RateLimit { return config.RateLimit{} })
+	breaker = newCircuitBreaker()
+)
+
 // NewClient returns wrapper around mongodbatlas.Client, which implements necessary functionality
-func NewClient(logger log.Logger, publicKey string, privateKey string, projectID string, atlasClusters []string) (*AtlasClient, error) {
+// for the projects, organization and credentials of cfg.
+// The rate limits of cfg apply once the client is committed with ApplyRateLimits.
+func NewClient(logger log.Logger, cfg *config.Config) (*AtlasClient, error) {
+	mongodbatlasClient, err := newMongodbatlasClient(logger, cfg.PublicKey, cfg.PrivateKey, cfg.RequestTimeout, limiter, breaker)
+	if err != nil {
+		return nil, err
+	}
+
+	projectClients := make(map[string]*mongodbatlas.Client)
+	for _, project := range cfg.Projects {
+		if project.PublicKey == "" {
+			continue
+		}
+		projectClients[project.ID], err = newMongodbatlasClient(logger, project.PublicKey, project.PrivateKey, cfg.RequestTimeout, limiter, breaker)
+		if err != nil {
+			return nil, err
+		}
+	}
+	level.Debug(logger).Log("msg", "mongodbatlas client was successfully created")
+
+	return &AtlasClient{
+		mongodbatlasClient: mongodbatlasClient,
+		projectClients:     projectClients,
+		config:             cfg,
+		logger:             logger,
+	}, nil
+}
+
+// ApplyRateLimits applies the rate limits of the client's configuration to the requests of every client,
+// they share one limiter, so only the client in use should apply them.
+func (c *AtlasClient) ApplyRateLimits() {
+	limiter.setLimits(c.config.ProjectRateLimit)
+}
+
+func newMongodbatlasClient(logger log.Logger, publicKey, privateKey string, timeout time.Duration, limiter *rateLimiter, breaker *circuitBreaker) (*mongodbatlas.Client, error) {
 	t := digest.NewTransport(publicKey, privateKey)
+	//the limiter sits below the digest authentication, as the challenge requests count against the budget as well.
+	t.Transport = &limitedTransport{limiter: limiter, next: t.Transport}
 
 	tc, err := t.Client()
 	if err != nil {
@@ -54,77 +111,303 @@ func NewClient(logger log.Logger, publicKey string, privateKey string, projectID
 	//instrument the http client's transport by composing several promtthp RoundTrippers over the
 	//digest Transport. All of these are RoundTrippers.
 	tc.Transport = promhttp.InstrumentRoundTripperCounter(requestCounter, tc.Transport)
+	//retries go through the digest authentication again, every attempt is counted.
+	tc.Transport = newRetryTransport(tc.Transport, breaker)
+	//the deadline covers the retries, callers can set shorter ones with the context of the request.
+	tc.Timeout = timeout
 
-	mongodbatlasClient := mongodbatlas.NewClient(tc)
-	level.Debug(logger).Log("msg", "mongodbatlas client was successfully created")
+	return mongodbatlas.NewClient(tc), nil
+}
 
-	return &AtlasClient{
-		mongodbatlasClient: mongodbatlasClient,
-		projectID:          projectID,
-		atlasClusters:      atlasClusters,
-		logger:             logger,
-	}, nil
+//client returns the mongodbatlas.Client with the credentials of the project.
+func (c *AtlasClient) client(projectID string) *mongodbatlas.Client {
+	if projectClient, ok := c.projectClients[projectID]; ok {
+		return projectClient
+	}
+	return c.mongodbatlasClient
 }
 
-func (c *AtlasClient) ListProcesses() ([]*mongodbatlas.Process, *HTTPError) {
-	processes, r, err := c.mongodbatlasClient.Processes.List(context.Background(), c.projectID, nil)
+// ListProjects returns the ids of all projects the exporter should scrape.
+// In organization mode the projects are discovered on every call.
+func (c *AtlasClient) ListProjects(ctx context.Context) ([]string, *HTTPError) {
+	configuredProjectIDs := make([]string, len(c.config.Projects))
+	for i, project := range c.config.Projects {
+		configuredProjectIDs[i] = project.ID
+	}
+
+	if c.config.OrgID == "" {
+		return configuredProjectIDs, nil
+	}
+
+	orgProjectIDs, err := c.listOrgProjects(ctx)
 	if err != nil {
-		msg := "failed to list processes of the project"
-		level.Error(c.logger).Log("msg", msg, "project", c.projectID, "err", err)
-		return nil, &HTTPError{
-			Err:        err,
-			StatusCode: r.StatusCode,
+		return nil, err
+	}
+
+	projectIDs := make([]string, 0, len(orgProjectIDs)+len(configuredProjectIDs))
+	seen := make(map[string]bool, cap(projectIDs))
+	for _, projectID := range append(orgProjectIDs, configuredProjectIDs...) {
+		if !seen[projectID] {
+			seen[projectID] = true
+			projectIDs = append(projectIDs, projectID)
+		}
+	}
+	return projectIDs, nil
+}
+
+func (c *AtlasClient) listOrgProjects(ctx context.Context) ([]string, *HTTPError) {
+	var projectIDs []string
+	listOptions := &mongodbatlas.ListOptions{
+		PageNum:      1,
+		ItemsPerPage: maxPageSize,
+	}
+
+	for {
+		projects, r, err := c.mongodbatlasClient.Organizations.Projects(ctx, c.config.OrgID, listOptions)
+		if err != nil {
+			msg := "failed to list projects of the organization"
+			level.Error(c.logger).Log("msg", msg, "org", c.config.OrgID, "err", err)
+			return nil, newHTTPError(r, err)
+		}
+
+		for _, project := range projects.Results {
+			projectIDs = append(projectIDs, project.ID)
+		}
+
+		//the client does not copy the links of this endpoint into the response, so r.IsLastPage is always true.
+		if !hasNextPage(projects.Links) || len(projects.Results) == 0 {
+			return projectIDs, nil
+		}
+		listOptions.PageNum++
+	}
+}
+
+//hasNextPage reports whether the links of a paginated result point to a next page.
+func hasNextPage(links []*mongodbatlas.Link) bool {
+	for _, link := range links {
+		if link != nil && link.Rel == "next" {
+			return true
+		}
+	}
+	return false
+}
+
+// ListProcesses returns the processes of a project, filtered by the clusters configured for the project
+func (c *AtlasClient) ListProcesses(ctx context.Context, projectID string) ([]*mongodbatlas.Process, *HTTPError) {
+	var processes []*mongodbatlas.Process
+	listOptions := &mongodbatlas.ProcessesListOptions{
+		ListOptions: mongodbatlas.ListOptions{
+			PageNum:      1,
+			ItemsPerPage: maxPageSize,
+		},
+	}
+
+	for {
+		page, r, err := c.client(projectID).Processes.List(ctx, projectID, listOptions)
+		if err != nil {
+			msg := "failed to list processes of the project"
+			level.Error(c.logger).Log("msg", msg, "project", projectID, "err", err)
+			return nil, newHTTPError(r, err)
+		}
+
+		processes = append(processes, page...)
+
+		if r.IsLastPage() || len(page) == 0 {
+			//the clusters are filtered once every page is in.
+			return FilterProcesses(processes, c.config.ProjectClusters(projectID)), nil
 		}
+		listOptions.PageNum++
 	}
-	if len(c.atlasClusters) == 0 {
-		return processes, nil
+}
+
+// FilterProcesses returns the processes which belong to one of the clusters.
+// If no clusters are given all processes are returned.
+func FilterProcesses(processes []*mongodbatlas.Process, clusters []string) []*mongodbatlas.Process {
+	if len(clusters) == 0 {
+		return processes
 	}
 	filteredProceses := make([]*mongodbatlas.Process, 0, len(processes))
-	for _, clusterName := range c.atlasClusters {
+	for _, clusterName := range clusters {
 		for _, process := range processes {
 			if strings.HasPrefix(process.UserAlias, clusterName) {
 				filteredProceses = append(filteredProceses, process)
 			}
 		}
 	}
-	return filteredProceses, nil
+	return filteredProceses
 }
 
-func (c *AtlasClient) ListDisks(p *mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *HTTPError) {
-	disks, r, err := c.mongodbatlasClient.ProcessDisks.List(context.Background(), c.projectID, p.Hostname, p.Port, nil)
+// ListClusters returns the clusters of a project, filtered by the clusters configured for the project
+func (c *AtlasClient) ListClusters(ctx context.Context, projectID string) ([]mongodbatlas.Cluster, *HTTPError) {
+	var clusters []mongodbatlas.Cluster
+	listOptions := &mongodbatlas.ListOptions{
+		PageNum:      1,
+		ItemsPerPage: maxPageSize,
+	}
+
+	for {
+		page, r, err := c.client(projectID).Clusters.List(ctx, projectID, listOptions)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "failed to list clusters of the project", "project", projectID, "err", err)
+			return nil, newHTTPError(r, err)
+		}
 
-	if err != nil {
-		return nil, &HTTPError{
-			StatusCode: r.StatusCode,
-			Err:        err,
+		clusters = append(clusters, page...)
+
+		if r.IsLastPage() || len(page) == 0 {
+			break
+		}
+		listOptions.PageNum++
+	}
+
+	configuredClusters := c.config.ProjectClusters(projectID)
+	if len(configuredClusters) == 0 {
+		return clusters, nil
+	}
+	filteredClusters := make([]mongodbatlas.Cluster, 0, len(clusters))
+	for _, clusterName := range configuredClusters {
+		for _, cluster := range clusters {
+			if cluster.Name == clusterName {
+				filteredClusters = append(filteredClusters, cluster)
+			}
+		}
+	}
+	return filteredClusters, nil
+}
+
+// ListAlerts returns the alerts of a project with the given status
+func (c *AtlasClient) ListAlerts(ctx context.Context, projectID, status string) ([]mongodbatlas.Alert, *HTTPError) {
+	var alerts []mongodbatlas.Alert
+	listOptions := &mongodbatlas.AlertsListOptions{
+		Status: status,
+		ListOptions: mongodbatlas.ListOptions{
+			PageNum:      1,
+			ItemsPerPage: maxPageSize,
+		},
+	}
+
+	for {
+		page, r, err := c.client(projectID).Alerts.List(ctx, projectID, listOptions)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "failed to list alerts of the project", "project", projectID, "status", status, "err", err)
+			return nil, newHTTPError(r, err)
+		}
+
+		alerts = append(alerts, page.Results...)
+
+		if r.IsLastPage() || len(page.Results) == 0 {
+			return alerts, nil
+		}
+		listOptions.PageNum++
+	}
+}
+
+// ListEvents returns the events of a project created at or after minDate
+func (c *AtlasClient) ListEvents(ctx context.Context, projectID string, minDate time.Time) ([]*mongodbatlas.Event, *HTTPError) {
+	var events []*mongodbatlas.Event
+	listOptions := &mongodbatlas.EventListOptions{
+		ListOptions: mongodbatlas.ListOptions{
+			PageNum:      1,
+			ItemsPerPage: maxPageSize,
+		},
+		MinDate: minDate.UTC().Format(time.RFC3339),
+	}
+
+	for {
+		page, r, err := c.client(projectID).Events.ListProjectEvents(ctx, projectID, listOptions)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "failed to list events of the project", "project", projectID, "err", err)
+			return nil, newHTTPError(r, err)
+		}
+
+		events = append(events, page.Results...)
+
+		if r.IsLastPage() || len(page.Results) == 0 {
+			return events, nil
+		}
+		listOptions.PageNum++
+	}
+}
+
+// ListSnapshots returns the Cloud Provider Snapshots of a cluster
+func (c *AtlasClient) ListSnapshots(ctx context.Context, projectID, clusterName string) ([]*mongodbatlas.CloudProviderSnapshot, *HTTPError) {
+	var snapshots []*mongodbatlas.CloudProviderSnapshot
+	params := &mongodbatlas.SnapshotReqPathParameters{GroupID: projectID, ClusterName: clusterName}
+	listOptions := &mongodbatlas.ListOptions{
+		PageNum:      1,
+		ItemsPerPage: maxPageSize,
+	}
+
+	for {
+		page, r, err := c.client(projectID).CloudProviderSnapshots.GetAllCloudProviderSnapshots(ctx, params, listOptions)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "failed to list snapshots of the cluster", "project", projectID, "cluster", clusterName, "err", err)
+			return nil, newHTTPError(r, err)
+		}
+
+		snapshots = append(snapshots, page.Results...)
+
+		if r.IsLastPage() || len(page.Results) == 0 {
+			return snapshots, nil
+		}
+		listOptions.PageNum++
+	}
+}
+
+// ListRestoreJobs returns the Cloud Provider Snapshot restore jobs of a cluster
+func (c *AtlasClient) ListRestoreJobs(ctx context.Context, projectID, clusterName string) ([]*mongodbatlas.CloudProviderSnapshotRestoreJob, *HTTPError) {
+	var jobs []*mongodbatlas.CloudProviderSnapshotRestoreJob
+	params := &mongodbatlas.SnapshotReqPathParameters{GroupID: projectID, ClusterName: clusterName}
+	listOptions := &mongodbatlas.ListOptions{
+		PageNum:      1,
+		ItemsPerPage: maxPageSize,
+	}
+
+	for {
+		page, r, err := c.client(projectID).CloudProviderSnapshotRestoreJobs.List(ctx, params, listOptions)
+		if err != nil {
+			level.Error(c.logger).Log("msg", "failed to list restore jobs of the cluster", "project", projectID, "cluster", clusterName, "err", err)
+			return nil, newHTTPError(r, err)
+		}
+
+		jobs = append(jobs, page.Results...)
+
+		if r.IsLastPage() || len(page.Results) == 0 {
+			return jobs, nil
 		}
+		listOptions.PageNum++
+	}
+}
+
+func (c *AtlasClient) ListDisks(ctx context.Context, p *mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *HTTPError) {
+	disks, r, err := c.client(p.GroupID).ProcessDisks.List(ctx, p.GroupID, p.Hostname, p.Port, nil)
+
+	if err != nil {
+		return nil, newHTTPError(r, err)
 	}
 	return disks.Results, nil
 }
 
-func (c *AtlasClient) listProcessDiskMeasurements(host string, port int, diskName string) (*mongodbatlas.ProcessDiskMeasurements, error) {
-	measurements, _, err := c.mongodbatlasClient.ProcessDiskMeasurements.List(context.Background(), c.projectID, host, port, diskName, opts)
+func (c *AtlasClient) listProcessDiskMeasurements(ctx context.Context, projectID, host string, port int, diskName string) (*mongodbatlas.ProcessDiskMeasurements, error) {
+	measurements, _, err := c.client(projectID).ProcessDiskMeasurements.List(ctx, projectID, host, port, diskName, measurementOptions(c.config.DiskResolution()))
 	if err != nil {
 		return nil, err
 	}
 	return measurements, nil
 }
 
-func (c *AtlasClient) listProcessMeasurements(host string, port int) (*mongodbatlas.ProcessMeasurements, *HTTPError) {
-	measurements, r, err := c.mongodbatlasClient.ProcessMeasurements.List(context.Background(), c.projectID, host, port, opts)
+func (c *AtlasClient) listProcessMeasurements(ctx context.Context, projectID, host string, port int) (*mongodbatlas.ProcessMeasurements, *HTTPError) {
+	measurements, r, err := c.client(projectID).ProcessMeasurements.List(ctx, projectID, host, port, measurementOptions(c.config.ProcessResolution()))
 	if err != nil {
-		return nil, &HTTPError{
-			Err:        err,
-			StatusCode: r.StatusCode,
-		}
+		return nil, newHTTPError(r, err)
 	}
 	return measurements, nil
 }
 
-// GetDiskMeasurements returns measurements for all disks of a Project
-func (c *AtlasClient) GetDiskMeasurements(process *measurer.Process, disk *measurer.Disk) error {
+// GetDiskMeasurements returns measurements for a disk of a process
+func (c *AtlasClient) GetDiskMeasurements(ctx context.Context, process *measurer.Process, disk *measurer.Disk) error {
 
-	measurements, err := c.listProcessDiskMeasurements(process.Hostname, process.Port, disk.PartitionName)
+	measurements, err := c.listProcessDiskMeasurements(WithPriority(ctx, PriorityMeasurement), process.ProjectID, process.Hostname, process.Port, disk.PartitionName)
 	if err != nil {
 		return err
 	}
@@ -141,9 +424,9 @@ func (c *AtlasClient) GetDiskMeasurements(process *measurer.Process, disk *measu
 	return err
 }
 
-// GetProcessMeasurements returns measurements for all processes of a Project
-func (c *AtlasClient) GetProcessMeasurements(measurer measurer.Process) (map[m.MeasurementID]*m.Measurement, error) {
-	measurements, err := c.listProcessMeasurements(measurer.Hostname, measurer.Port)
+// GetProcessMeasurements returns measurements for a process
+func (c *AtlasClient) GetProcessMeasurements(ctx context.Context, measurer measurer.Process) (map[m.MeasurementID]*m.Measurement, error) {
+	measurements, err := c.listProcessMeasurements(WithPriority(ctx, PriorityMeasurement), measurer.ProjectID, measurer.Hostname, measurer.Port)
 	if err != nil {
 		return nil, err
 	}
@@ -160,27 +443,29 @@ func (c *AtlasClient) GetProcessMeasurements(measurer measurer.Process) (map[m.M
 }
 
 // GetDiskMeasurementsMetadata returns name and unit of all available Disk measurements
-func (c *AtlasClient) GetDiskMeasurementsMetadata(p *measurer.Process, d *measurer.Disk) (map[m.MeasurementID]*m.MeasurementMetadata, error) {
-	result, err := c.getDiskMeasurementsForMetadata(p.Hostname, p.Port, d.PartitionName)
+func (c *AtlasClient) GetDiskMeasurementsMetadata(ctx context.Context, p *measurer.Process, d *measurer.Disk) (map[m.MeasurementID]*m.MeasurementMetadata, error) {
+	result, err := c.getDiskMeasurementsForMetadata(ctx, p.ProjectID, p.Hostname, p.Port, d.PartitionName)
 
 	if err != nil {
 		return nil, err
 	}
-
-	if len(result) < 1 {
-		return nil, errors.New("can't find any resource with disk measurements, please create Atlas resources first and restart the exporter")
-	}
 	return result, nil
 }
 
-func (c *AtlasClient) getDiskMeasurementsForMetadata(host string, port int, partitionName string) (map[m.MeasurementID]*m.MeasurementMetadata, error) {
+func (c *AtlasClient) getDiskMeasurementsForMetadata(ctx context.Context, projectID, host string, port int, partitionName string) (map[m.MeasurementID]*m.MeasurementMetadata, error) {
 	// At the moment of writing: 1 mongod disk expose 10 measurements
 	result := make(map[m.MeasurementID]*m.MeasurementMetadata, 10)
-	diskMeasurements, err := c.listProcessDiskMeasurements(host, port, partitionName)
+	diskMeasurements, err := c.listProcessDiskMeasurements(ctx, projectID, host, port, partitionName)
 	if err != nil {
 		return nil, err
 	}
+	if len(diskMeasurements.Measurements) < 1 {
+		return nil, errors.New("can't find any resource with disk measurements, please create Atlas resources first and restart the exporter")
+	}
 	for _, measurement := range diskMeasurements.Measurements {
+		if !c.config.Metrics.Allowed(measurement.Name) {
+			continue
+		}
 		metadata := &m.MeasurementMetadata{
 			Name:  measurement.Name,
 			Units: m.UnitEnum(measurement.Units),
@@ -192,20 +477,24 @@ func (c *AtlasClient) getDiskMeasurementsForMetadata(host string, port int, part
 }
 
 // GetProcessMeasurementsMetadata returns name and unit of all available Process measurements
-func (c *AtlasClient) GetProcessMeasurementsMetadata(pMeasurer *measurer.Process) *HTTPError {
+func (c *AtlasClient) GetProcessMeasurementsMetadata(ctx context.Context, pMeasurer *measurer.Process) *HTTPError {
 	// At the moment of writing: mongod process expose 96 measurements
 	// measurements for mognod process and mongos process measurements contain different amount of measurements,
 	// mongod contains all measurements that mongos provides and some more specific to mongod measurements
 	// (like `CACHE_*`,  `DB_*`, `DOCUMENT_*`, `GLOBAL_LOCK_CURRENT_QUEUE_*`, etc)
 	pMeasurer.Metadata = make(map[m.MeasurementID]*m.MeasurementMetadata, 96)
 
-	processMeasurements, err := c.listProcessMeasurements(pMeasurer.Hostname, pMeasurer.Port)
+	processMeasurements, err := c.listProcessMeasurements(ctx, pMeasurer.ProjectID, pMeasurer.Hostname, pMeasurer.Port)
 	if err != nil {
 		return err
 	}
 
 	if len(processMeasurements.Measurements) > 0 {
 		for _, measurement := range processMeasurements.Measurements {
+			//measurements filtered by the configuration never become metrics.
+			if !c.config.Metrics.Allowed(measurement.Name) {
+				continue
+			}
 			metadata := &m.MeasurementMetadata{
 				Name:  measurement.Name,
 				Units: m.UnitEnum(measurement.Units),
@@ -214,7 +503,7 @@ func (c *AtlasClient) GetProcessMeasurementsMetadata(pMeasurer *measurer.Process
 		}
 	}
 
-	if len(pMeasurer.Metadata) < 1 {
+	if len(processMeasurements.Measurements) < 1 {
 		return &HTTPError{
 			Err: errors.New("can't find any resource with process measurements, please create Atlas resources first and restart the exporter"),
 		}
@@ -222,3 +511,192 @@ func (c *AtlasClient) GetProcessMeasurementsMetadata(pMeasurer *measurer.Process
 
 	return nil
 }
+
+// ListDatabases returns the databases of a process, filtered by the configured database names.
+// If database measurements are disabled no database is returned.
+func (c *AtlasClient) ListDatabases(ctx context.Context, p *mongodbatlas.Process) ([]*mongodbatlas.ProcessDatabase, *HTTPError) {
+	if !c.config.Databases.Enabled {
+		return nil, nil
+	}
+
+	var databases []*mongodbatlas.ProcessDatabase
+	listOptions := &mongodbatlas.ListOptions{
+		PageNum:      1,
+		ItemsPerPage: maxPageSize,
+	}
+
+	for {
+		page, r, err := c.client(p.GroupID).ProcessDatabases.List(ctx, p.GroupID, p.Hostname, p.Port, listOptions)
+		if err != nil {
+			return nil, newHTTPError(r, err)
+		}
+
+		databases = append(databases, page.Results...)
+
+		//the client does not copy the links of this endpoint into the response, so r.IsLastPage is always true.
+		if !hasNextPage(page.Links) || len(page.Results) == 0 {
+			break
+		}
+		listOptions.PageNum++
+	}
+
+	filteredDatabases := make([]*mongodbatlas.ProcessDatabase, 0, len(databases))
+	for _, database := range databases {
+		if c.config.Databases.Allowed(database.DatabaseName) {
+			filteredDatabases = append(filteredDatabases, database)
+		}
+	}
+	return filteredDatabases, nil
+}
+
+func (c *AtlasClient) listProcessDatabaseMeasurements(ctx context.Context, projectID, host string, port int, databaseName string) ([]*mongodbatlas.Measurements, error) {
+	measurements, r, err := c.client(projectID).ProcessDatabaseMeasurements.List(ctx, projectID, host, port, databaseName, measurementOptions(c.config.DatabaseResolution()))
+	if err != nil {
+		return nil, newHTTPError(r, err)
+	}
+	if measurements.ProcessMeasurements == nil {
+		return nil, nil
+	}
+	return measurements.Measurements, nil
+}
+
+// GetDatabaseMeasurements returns measurements for a database of a process
+func (c *AtlasClient) GetDatabaseMeasurements(ctx context.Context, process *measurer.Process, database *measurer.Database) error {
+	measurements, err := c.listProcessDatabaseMeasurements(WithPriority(ctx, PriorityMeasurement), process.ProjectID, process.Hostname, process.Port, database.DatabaseName)
+	if err != nil {
+		return err
+	}
+
+	database.Measurements = make(map[m.MeasurementID]*m.Measurement, len(measurements))
+	for _, measurement := range measurements {
+		measurementID := m.NewMeasurementID(measurement.Name, measurement.Units)
+		database.Measurements[measurementID] = &m.Measurement{
+			DataPoints: measurement.DataPoints,
+			Units:      m.UnitEnum(measurement.Units),
+		}
+	}
+
+	return nil
+}
+
+// GetDatabaseMeasurementsMetadata returns name and unit of all available Database measurements
+func (c *AtlasClient) GetDatabaseMeasurementsMetadata(ctx context.Context, p *measurer.Process, d *measurer.Database) (map[m.MeasurementID]*m.MeasurementMetadata, error) {
+	// At the moment of writing: 1 database exposes 8 measurements
+	result := make(map[m.MeasurementID]*m.MeasurementMetadata, 8)
+	measurements, err := c.listProcessDatabaseMeasurements(ctx, p.ProjectID, p.Hostname, p.Port, d.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+	if len(measurements) < 1 {
+		return nil, errors.New("can't find any database measurements for database " + d.DatabaseName)
+	}
+	for _, measurement := range measurements {
+		if !c.config.Metrics.Allowed(measurement.Name) {
+			continue
+		}
+		metadata := &m.MeasurementMetadata{
+			Name:  measurement.Name,
+			Units: m.UnitEnum(measurement.Units),
+		}
+		result[metadata.ID()] = metadata
+	}
+
+	return result, nil
+}
+
+// GetSuggestedIndexes returns the indexes the Performance Advisor suggests for a process.
+// Without performance_advisor.enabled no request is made and nil is returned.
+func (c *AtlasClient) GetSuggestedIndexes(ctx context.Context, p *measurer.Process) ([]*mongodbatlas.SuggestedIndex, *HTTPError) {
+	if !c.config.PerformanceAdvisor.Enabled {
+		return nil, nil
+	}
+
+	indexes, r, err := c.client(p.ProjectID).PerformanceAdvisor.GetSuggestedIndexes(ctx, p.ProjectID, processName(p), nil)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "failed to get suggested indexes of the process", "project", p.ProjectID, "process", processName(p), "err", err)
+		return nil, newHTTPError(r, err)
+	}
+	return indexes.SuggestedIndexes, nil
+}
+
+// GetSlowQueries returns the slow query log lines the Performance Advisor found for a process.
+// Without performance_advisor.enabled no request is made and nil is returned.
+func (c *AtlasClient) GetSlowQueries(ctx context.Context, p *measurer.Process) ([]*mongodbatlas.SlowQuery, *HTTPError) {
+	if !c.config.PerformanceAdvisor.Enabled {
+		return nil, nil
+	}
+
+	queries, r, err := c.client(p.ProjectID).PerformanceAdvisor.GetSlowQueries(ctx, p.ProjectID, processName(p), nil)
+	if err != nil {
+		level.Error(c.logger).Log("msg", "failed to get slow queries of the process", "project", p.ProjectID, "process", processName(p), "err", err)
+		return nil, newHTTPError(r, err)
+	}
+	return queries.SlowQuery, nil
+}
+
+//processName is the hostname:port the Performance Advisor API identifies processes by.
+func processName(p *measurer.Process) string {
+	return p.Hostname + ":" + strconv.Itoa(p.Port)
+}
+
+// GetProcessMeasurementsRange returns the measurements of a process between start and end
+// with the configured granularity. It is used to backfill history, not by the collectors.
+func (c *AtlasClient) GetProcessMeasurementsRange(ctx context.Context, p *measurer.Process, start, end time.Time) ([]*mongodbatlas.Measurements, *HTTPError) {
+	measurements, r, err := c.client(p.ProjectID).ProcessMeasurements.List(ctx, p.ProjectID, p.Hostname, p.Port, rangeOptions(c.config.ProcessResolution(), start, end))
+	if err != nil {
+		return nil, newHTTPError(r, err)
+	}
+	return c.allowedMeasurements(measurements.Measurements), nil
+}
+
+// GetDiskMeasurementsRange returns the measurements of a disk between start and end
+// with the configured granularity. It is used to backfill history, not by the collectors.
+func (c *AtlasClient) GetDiskMeasurementsRange(ctx context.Context, p *measurer.Process, d *measurer.Disk, start, end time.Time) ([]*mongodbatlas.Measurements, *HTTPError) {
+	measurements, r, err := c.client(p.ProjectID).ProcessDiskMeasurements.List(ctx, p.ProjectID, p.Hostname, p.Port, d.PartitionName, rangeOptions(c.config.DiskResolution(), start, end))
+	if err != nil {
+		return nil, newHTTPError(r, err)
+	}
+	return c.allowedMeasurements(measurements.Measurements), nil
+}
+
+func measurementOptions(resolution config.Resolution) *mongodbatlas.ProcessMeasurementListOptions {
+	return &mongodbatlas.ProcessMeasurementListOptions{
+		Granularity: resolution.Granularity,
+		Period:      resolution.Period,
+	}
+}
+
+//rangeOptions replaces the configured period with a fixed time range.
+func rangeOptions(resolution config.Resolution, start, end time.Time) *mongodbatlas.ProcessMeasurementListOptions {
+	return &mongodbatlas.ProcessMeasurementListOptions{
+		Granularity: resolution.Granularity,
+		Start:       start.UTC().Format(time.RFC3339),
+		End:         end.UTC().Format(time.RFC3339),
+	}
+}
+
+func (c *AtlasClient) allowedMeasurements(measurements []*mongodbatlas.Measurements) []*mongodbatlas.Measurements {
+	result := make([]*mongodbatlas.Measurements, 0, len(measurements))
+	for _, measurement := range measurements {
+		if c.config.Metrics.Allowed(measurement.Name) {
+			result = append(result, measurement)
+		}
+	}
+	return result
+}
+
+// MapMeasurement applies the configured mappings to an Atlas measurement name.
+func (c *AtlasClient) MapMeasurement(name string) (config.MappedMeasurement, bool) {
+	return c.config.MapMeasurement(name)
+}
+
+// UnitRule returns the configured rule of a unit the exporter does not know.
+func (c *AtlasClient) UnitRule(unit m.UnitEnum) (config.UnitRule, bool) {
+	rule, ok := c.config.Units[string(unit)]
+	return rule, ok
+}
+
+// AllowedMetricName reports whether a metric with the fully qualified name passes the configured name filter.
+func (c *AtlasClient) AllowedMetricName(fqName string) bool {
+	return c.config.Metrics.Names.Allowed(fqName)
+}
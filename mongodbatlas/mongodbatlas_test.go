@@ -0,0 +1,201 @@
+package mongodbatlas
+
+import (
+	"context"
+	"encoding/json"
+	"mongodbatlas_exporter/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+//newTestAtlasClient returns a client sending its requests to server.
+func newTestAtlasClient(t *testing.T, server *httptest.Server, cfg *config.Config) *AtlasClient {
+	client, err := mongodbatlas.New(server.Client(), mongodbatlas.SetBaseURL(server.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AtlasClient{
+		mongodbatlasClient: client,
+		config:             cfg,
+		logger:             log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr)),
+	}
+}
+
+//orgProjectsHandler serves the projects of the organization org, pageSize projects per page.
+func orgProjectsHandler(t *testing.T, projectIDs []string, pageSize int, requests *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.URL.Path != "/api/atlas/v1.0/orgs/org/groups" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("pageNum"))
+		start, end := (page-1)*pageSize, page*pageSize
+		if end > len(projectIDs) {
+			end = len(projectIDs)
+		}
+
+		result := mongodbatlas.Projects{TotalCount: len(projectIDs)}
+		for _, id := range projectIDs[start:end] {
+			result.Results = append(result.Results, &mongodbatlas.Project{ID: id})
+		}
+		if end < len(projectIDs) {
+			result.Links = []*mongodbatlas.Link{{Rel: "next", Href: "http://atlas/next"}}
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// TestListProjects_organization checks that every page of the organization's projects is listed
+// and that configured projects are added once.
+func TestListProjects_organization(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	server := httptest.NewServer(orgProjectsHandler(t, []string{"a", "b", "c", "d", "e"}, 2, &requests))
+	defer server.Close()
+
+	client := newTestAtlasClient(t, server, &config.Config{
+		OrgID:    "org",
+		Projects: []config.Project{{ID: "c"}, {ID: "outside-org"}},
+	})
+
+	projectIDs, err := client.ListProjects(context.Background())
+
+	assert.Nil(err)
+	assert.Equal([]string{"a", "b", "c", "d", "e", "outside-org"}, projectIDs)
+	assert.Equal(3, requests)
+}
+
+func TestListProjects_organizationError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := newTestAtlasClient(t, server, &config.Config{OrgID: "org"})
+
+	projectIDs, err := client.ListProjects(context.Background())
+
+	assert.Nil(t, projectIDs)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, http.StatusUnauthorized, err.StatusCode)
+	}
+}
+
+// TestListProjects_configured checks that without an organization no request is sent.
+func TestListProjects_configured(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(orgProjectsHandler(t, nil, 1, &requests))
+	defer server.Close()
+
+	client := newTestAtlasClient(t, server, &config.Config{Projects: []config.Project{{ID: "a"}, {ID: "b"}}})
+
+	projectIDs, err := client.ListProjects(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, projectIDs)
+	assert.Equal(t, 0, requests)
+}
+
+//pagedHandler serves the items of path, pageSize items per page with a link to the next page if there is one.
+func pagedHandler(t *testing.T, path string, items []interface{}, pageSize int, requests *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.URL.Path != path {
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("pageNum"))
+		start, end := (page-1)*pageSize, page*pageSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		result := map[string]interface{}{"results": items[start:end], "totalCount": len(items)}
+		if end < len(items) {
+			result["links"] = []*mongodbatlas.Link{{Rel: "next", Href: "http://atlas/next"}}
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// TestListProcesses checks that every page of the processes is listed before they are filtered by cluster.
+func TestListProcesses(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	var processes []interface{}
+	for _, alias := range []string{"a-shard-00-00", "b-shard-00-00", "a-shard-00-01", "b-shard-00-01", "a-shard-00-02"} {
+		processes = append(processes, &mongodbatlas.Process{ID: alias + ":27017", UserAlias: alias})
+	}
+	server := httptest.NewServer(pagedHandler(t, "/api/atlas/v1.0/groups/project/processes", processes, 2, &requests))
+	defer server.Close()
+
+	client := newTestAtlasClient(t, server, &config.Config{Projects: []config.Project{{ID: "project", Clusters: []string{"a"}}}})
+
+	listed, err := client.ListProcesses(context.Background(), "project")
+
+	assert.Nil(err)
+	var aliases []string
+	for _, process := range listed {
+		aliases = append(aliases, process.UserAlias)
+	}
+	assert.Equal([]string{"a-shard-00-00", "a-shard-00-01", "a-shard-00-02"}, aliases)
+	assert.Equal(3, requests)
+}
+
+// TestListClusters checks that every page of the clusters is listed before they are filtered.
+func TestListClusters(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	var clusters []interface{}
+	for _, name := range []string{"a", "b", "c"} {
+		clusters = append(clusters, &mongodbatlas.Cluster{Name: name})
+	}
+	server := httptest.NewServer(pagedHandler(t, "/api/atlas/v1.0/groups/project/clusters", clusters, 2, &requests))
+	defer server.Close()
+
+	client := newTestAtlasClient(t, server, &config.Config{Projects: []config.Project{{ID: "project", Clusters: []string{"a", "c"}}}})
+
+	listed, err := client.ListClusters(context.Background(), "project")
+
+	assert.Nil(err)
+	var names []string
+	for _, cluster := range listed {
+		names = append(names, cluster.Name)
+	}
+	assert.Equal([]string{"a", "c"}, names)
+	assert.Equal(2, requests)
+}
+
+// TestListDatabases checks that every page of the databases of a process is listed.
+func TestListDatabases(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	var databases []interface{}
+	for _, name := range []string{"admin", "local", "orders"} {
+		databases = append(databases, &mongodbatlas.ProcessDatabase{DatabaseName: name})
+	}
+	server := httptest.NewServer(pagedHandler(t, "/api/atlas/v1.0/groups/project/processes/host:27017/databases", databases, 2, &requests))
+	defer server.Close()
+
+	client := newTestAtlasClient(t, server, &config.Config{Databases: config.Databases{Enabled: true}})
+
+	listed, err := client.ListDatabases(context.Background(), &mongodbatlas.Process{GroupID: "project", Hostname: "host", Port: 27017})
+
+	assert.Nil(err)
+	var names []string
+	for _, database := range listed {
+		names = append(names, database.DatabaseName)
+	}
+	assert.Equal([]string{"admin", "local", "orders"}, names)
+	assert.Equal(2, requests)
+}
@@ -0,0 +1,243 @@
+package mongodbatlas
+
+import (
+	"context"
+	"mongodbatlas_exporter/config"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Priority decides which queued request gets the next token of a project's budget.
+type Priority int
+
+const (
+	//PriorityMetadata is used for listing resources and their available measurements.
+	//It is the default for requests without a priority.
+	PriorityMetadata Priority = iota
+	//PriorityMeasurement is used for the measurements that are reported on a scrape.
+	PriorityMeasurement
+)
+
+func (p Priority) String() string {
+	if p == PriorityMeasurement {
+		return "measurement"
+	}
+	return "metadata"
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose requests are queued with the given priority.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFromContext(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+var (
+	//the project id is the group id in the API paths, requests without one share the organization budget.
+	projectPathRegexp = regexp.MustCompile(`/groups/([^/]+)`)
+
+	rateLimiterQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mongodbatlas_http_rate_limiter_queue_depth",
+		Help: "Number of Atlas API requests waiting for the rate limiter.",
+	}, []string{"priority"})
+	rateLimiterWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongodbatlas_http_rate_limiter_wait_seconds",
+		Help:    "Time Atlas API requests that were throttled waited for the rate limiter, by whether they were sent or cancelled while waiting.",
+		Buckets: []float64{.1, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"priority", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(rateLimiterQueueDepth, rateLimiterWaitSeconds)
+}
+
+// rateLimiter keeps a token bucket per project, shared by the clients of all credentials.
+// Requests that find the bucket empty are queued, measurements before metadata,
+// and sent in that order as soon as tokens are available again.
+type rateLimiter struct {
+	limits  func(projectID string) config.RateLimit
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	limit  config.RateLimit
+	tokens float64
+	last   time.Time
+	//queues holds the waiting requests by priority, each in arrival order.
+	queues [PriorityMeasurement + 1][]*waiter
+	timer  *time.Timer
+}
+
+type waiter struct {
+	ready chan struct{}
+	//cancelled waiters are skipped when they reach the front of their queue.
+	cancelled bool
+}
+
+func newRateLimiter(limits func(projectID string) config.RateLimit) *rateLimiter {
+	return &rateLimiter{
+		limits:  limits,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// setLimits replaces the limits of every project, e.g. on a configuration reload.
+// The buckets keep their tokens and waiting requests, so a reload neither resets nor drops the budget in use.
+func (l *rateLimiter) setLimits(limits func(projectID string) config.RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limits = limits
+	for projectID, b := range l.buckets {
+		//the tokens accrued so far count at the old rate.
+		l.refill(b)
+		b.limit = limits(projectID)
+		if b.tokens > float64(b.limit.Burst) {
+			b.tokens = float64(b.limit.Burst)
+		}
+		//a stopped timer is scheduled again at the new rate, one that fired already reschedules itself.
+		if b.timer != nil && b.timer.Stop() {
+			b.timer = nil
+			l.schedule(b)
+		}
+	}
+}
+
+// limitedTransport is a http.RoundTripper that waits for the rate limiter before every request.
+type limitedTransport struct {
+	limiter *rateLimiter
+	next    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context(), projectFromPath(req.URL.Path)); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+func projectFromPath(path string) string {
+	if match := projectPathRegexp.FindStringSubmatch(path); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// wait blocks until the project's bucket has a token for the request or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, projectID string) error {
+	priority := priorityFromContext(ctx)
+
+	l.mu.Lock()
+	b := l.bucket(projectID)
+	l.refill(b)
+	if b.tokens >= 1 && b.waiting() == 0 {
+		b.tokens--
+		l.mu.Unlock()
+		return nil
+	}
+
+	w := &waiter{ready: make(chan struct{})}
+	b.queues[priority] = append(b.queues[priority], w)
+	rateLimiterQueueDepth.WithLabelValues(priority.String()).Inc()
+	l.schedule(b)
+	l.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-w.ready:
+		rateLimiterWaitSeconds.WithLabelValues(priority.String(), "sent").Observe(time.Since(start).Seconds())
+		return nil
+	case <-ctx.Done():
+		//the longest waits are the ones the request gave up on.
+		rateLimiterWaitSeconds.WithLabelValues(priority.String(), "cancelled").Observe(time.Since(start).Seconds())
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		select {
+		case <-w.ready:
+			//the token was handed over just now, it is lost with the request.
+		default:
+			w.cancelled = true
+			rateLimiterQueueDepth.WithLabelValues(priority.String()).Dec()
+		}
+		return ctx.Err()
+	}
+}
+
+func (l *rateLimiter) bucket(projectID string) *bucket {
+	b, ok := l.buckets[projectID]
+	if !ok {
+		limit := l.limits(projectID)
+		b = &bucket{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+		l.buckets[projectID] = b
+	}
+	return b
+}
+
+func (l *rateLimiter) refill(b *bucket) {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Minutes() * float64(b.limit.RequestsPerMinute)
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+}
+
+// release hands the available tokens to the waiting requests, highest priority first.
+func (l *rateLimiter) release(b *bucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b.timer = nil
+	l.refill(b)
+	for p := PriorityMeasurement; p >= PriorityMetadata; p-- {
+		for len(b.queues[p]) > 0 && b.tokens >= 1 {
+			w := b.queues[p][0]
+			b.queues[p] = b.queues[p][1:]
+			if w.cancelled {
+				continue
+			}
+			b.tokens--
+			rateLimiterQueueDepth.WithLabelValues(p.String()).Dec()
+			close(w.ready)
+		}
+	}
+	l.schedule(b)
+}
+
+// schedule wakes up release when the next token is available, if requests are waiting.
+func (l *rateLimiter) schedule(b *bucket) {
+	if b.timer != nil || b.waiting() == 0 {
+		return
+	}
+	missing := 1 - b.tokens
+	if missing < 0 {
+		missing = 0
+	}
+	delay := time.Duration(missing / float64(b.limit.RequestsPerMinute) * float64(time.Minute))
+	b.timer = time.AfterFunc(delay, func() {
+		l.release(b)
+	})
+}
+
+func (b *bucket) waiting() int {
+	n := 0
+	for _, queue := range b.queues {
+		for _, w := range queue {
+			if !w.cancelled {
+				n++
+			}
+		}
+	}
+	return n
+}
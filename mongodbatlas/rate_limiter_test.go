@@ -0,0 +1,120 @@
+package mongodbatlas
+
+import (
+	"context"
+	"mongodbatlas_exporter/config"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectFromPath(t *testing.T) {
+	assert.Equal(t, "5e2211c17a3e5a48f5497de3", projectFromPath("/api/atlas/v1.0/groups/5e2211c17a3e5a48f5497de3/processes"))
+	assert.Equal(t, "", projectFromPath("/api/atlas/v1.0/orgs/org/groups"))
+}
+
+// TestRateLimiter checks that queued measurements are sent before metadata
+// requests that were queued earlier.
+func TestRateLimiter(t *testing.T) {
+	assert := assert.New(t)
+	limiter := newRateLimiter(func(string) config.RateLimit {
+		return config.RateLimit{RequestsPerMinute: 600, Burst: 1}
+	})
+	ctx := context.Background()
+
+	//the burst is used up by the first request.
+	assert.NoError(limiter.wait(ctx, "project"))
+
+	done := make(chan Priority, 2)
+	go func() {
+		limiter.wait(ctx, "project")
+		done <- PriorityMetadata
+	}()
+	waitForQueue(t, limiter, "project", 1)
+	go func() {
+		limiter.wait(WithPriority(ctx, PriorityMeasurement), "project")
+		done <- PriorityMeasurement
+	}()
+	waitForQueue(t, limiter, "project", 2)
+
+	assert.Equal(PriorityMeasurement, <-done)
+	assert.Equal(PriorityMetadata, <-done)
+
+	//other projects have their own budget.
+	assert.NoError(limiter.wait(ctx, "other-project"))
+}
+
+func TestRateLimiter_cancel(t *testing.T) {
+	limiter := newRateLimiter(func(string) config.RateLimit {
+		return config.RateLimit{RequestsPerMinute: 1, Burst: 1}
+	})
+	assert.NoError(t, limiter.wait(context.Background(), "project"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cancelled := waitSamples(t, "cancelled")
+
+	assert.Equal(t, context.DeadlineExceeded, limiter.wait(ctx, "project"))
+	waitForQueue(t, limiter, "project", 0)
+	//the wait of the cancelled request is observed as well.
+	assert.Equal(t, cancelled+1, waitSamples(t, "cancelled"))
+}
+
+//waitSamples returns the number of waits of metadata requests observed with the result.
+func waitSamples(t *testing.T, result string) uint64 {
+	metric := &dto.Metric{}
+	if err := rateLimiterWaitSeconds.WithLabelValues(PriorityMetadata.String(), result).(prometheus.Histogram).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+// TestRateLimiter_setLimits checks that new limits apply to the requests already waiting
+// and that the tokens left are kept.
+func TestRateLimiter_setLimits(t *testing.T) {
+	assert := assert.New(t)
+	limiter := newRateLimiter(func(string) config.RateLimit {
+		return config.RateLimit{RequestsPerMinute: 1, Burst: 2}
+	})
+	ctx := context.Background()
+	assert.NoError(limiter.wait(ctx, "project"))
+
+	//the token left is not refilled to the new burst.
+	limiter.setLimits(func(string) config.RateLimit {
+		return config.RateLimit{RequestsPerMinute: 1, Burst: 3}
+	})
+	assert.NoError(limiter.wait(ctx, "project"))
+
+	done := make(chan error)
+	go func() {
+		done <- limiter.wait(ctx, "project")
+	}()
+	waitForQueue(t, limiter, "project", 1)
+
+	//at one request per minute the waiting request would time out.
+	limiter.setLimits(func(string) config.RateLimit {
+		return config.RateLimit{RequestsPerMinute: 6000, Burst: 3}
+	})
+	select {
+	case err := <-done:
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("the waiting request was not released at the new rate")
+	}
+}
+
+func waitForQueue(t *testing.T, limiter *rateLimiter, projectID string, n int) {
+	for i := 0; i < 100; i++ {
+		limiter.mu.Lock()
+		waiting := limiter.bucket(projectID).waiting()
+		limiter.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d waiting requests", n)
+}
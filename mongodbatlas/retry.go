@@ -0,0 +1,108 @@
+package mongodbatlas
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+)
+
+const (
+	maxRetries = 3
+	//maxRetryAfter is the longest Retry-After that is waited for, longer waits are left to the next scrape.
+	maxRetryAfter = 30 * time.Second
+)
+
+// retryTransport is a http.RoundTripper that retries requests Atlas answered with
+// 429 Too Many Requests or 503 Service Unavailable, and records the results in the circuit breaker.
+type retryTransport struct {
+	next    http.RoundTripper
+	breaker *circuitBreaker
+	//newBackOff returns the backoff of a request, tests use shorter intervals.
+	newBackOff func() backoff.BackOff
+}
+
+func newRetryTransport(next http.RoundTripper, breaker *circuitBreaker) *retryTransport {
+	return &retryTransport{
+		next:       next,
+		breaker:    breaker,
+		newBackOff: newBackOff,
+	}
+}
+
+//newBackOff returns a jittered exponential backoff.
+func newBackOff() backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = time.Second
+	b.MaxInterval = 10 * time.Second
+	b.MaxElapsedTime = time.Minute
+	return backoff.WithMaxRetries(b, maxRetries)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	projectID := projectFromPath(req.URL.Path)
+	//only requests without side effects are sent twice.
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+	b := backoff.WithContext(t.newBackOff(), req.Context())
+
+	for {
+		if err := t.breaker.allow(projectID); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			if req.Context().Err() == nil {
+				t.breaker.failure(projectID, 0)
+			}
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+			t.breaker.success(projectID)
+			return resp, nil
+		}
+
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		t.breaker.failure(projectID, retryAfter)
+
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+		delay := b.NextBackOff()
+		if !idempotent || !retryable || delay == backoff.Stop || retryAfter > maxRetryAfter {
+			return resp, nil
+		}
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+
+		//the connection can only be reused if the body was read completely.
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// parseRetryAfter returns the wait of a Retry-After header, either in seconds or as a date.
+// It is 0 if the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
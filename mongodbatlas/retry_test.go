@@ -0,0 +1,87 @@
+package mongodbatlas
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRetryTransport() *retryTransport {
+	t := newRetryTransport(http.DefaultTransport, newCircuitBreaker())
+	t.newBackOff = func() backoff.BackOff {
+		return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, maxRetries)
+	}
+	return t
+}
+
+func TestRetryTransport(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newTestRetryTransport()}
+	resp, err := client.Get(server.URL + "/api/atlas/v1.0/groups/retry-project/processes")
+
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(3, requests)
+	assert.Equal(float64(0), testutil.ToFloat64(circuitOpen.WithLabelValues("retry-project")))
+}
+
+// TestRetryTransport_circuitOpen checks that persistent errors open the circuit
+// and that requests are rejected without reaching Atlas until it closes again.
+func TestRetryTransport_circuitOpen(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	transport := newTestRetryTransport()
+	client := &http.Client{Transport: transport}
+	url := server.URL + "/api/atlas/v1.0/groups/broken-project/processes"
+
+	//the first request and its retries fail, the second opens the circuit after one more failure.
+	resp, err := client.Get(url)
+	assert.NoError(err)
+	assert.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	_, err = client.Get(url)
+	assert.True(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(circuitFailureThreshold, requests)
+	assert.Equal(float64(1), testutil.ToFloat64(circuitOpen.WithLabelValues("broken-project")))
+
+	//half open, the next failure opens the circuit again immediately.
+	transport.breaker.circuits["broken-project"].openUntil = time.Now().Add(-time.Second)
+	_, err = client.Get(url)
+	assert.True(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(circuitFailureThreshold+1, requests)
+
+	//other projects are not affected.
+	_, err = client.Get(server.URL + "/api/atlas/v1.0/groups/other-project/processes")
+	assert.False(errors.Is(err, ErrCircuitOpen))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5", now))
+	assert.Equal(t, time.Minute, parseRetryAfter("Mon, 01 Mar 2021 12:01:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
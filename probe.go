@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"mongodbatlas_exporter/collector"
+	"mongodbatlas_exporter/mongodbatlas"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeHandler serves the metrics of a single project, optionally narrowed down to one cluster,
+// in the style of the blackbox_exporter. Every request builds its own registry so the set of
+// scraped projects and clusters is controlled by the Prometheus configuration.
+// The collectors of a probe live for a single request, so the options that depend on earlier scrapes are turned off.
+func probeHandler(logger log.Logger, client func() mongodbatlas.Client, options collector.ProcessOptions) http.HandlerFunc {
+	//rate counters would restart at 0 and every datapoint would be new on every probe,
+	//and there is no background poll filling a cache.
+	options.Counters = false
+	options.Timestamps = false
+	options.Cached = false
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		projectID := params.Get("project")
+		if projectID == "" {
+			http.Error(w, "project parameter is missing", http.StatusBadRequest)
+			return
+		}
+		cluster := params.Get("cluster")
+
+		probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mongodbatlas_probe_success",
+			Help: "Were all processes of the probed target successfully discovered.",
+		})
+		probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mongodbatlas_probe_duration_seconds",
+			Help: "How long the discovery of the probed target took in seconds.",
+		})
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(probeSuccess, probeDuration)
+		//the process collectors are gathered with the context of the request.
+		processes := collector.NewRegistry()
+
+		logger := log.With(logger, "project", projectID, "cluster", cluster)
+		start := time.Now()
+		if probe(r.Context(), logger, client(), options, processes, projectID, cluster) {
+			probeSuccess.Set(1)
+		}
+		probeDuration.Set(time.Since(start).Seconds())
+
+		processes.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
+
+// probe registers a process collector for every process of the target in registry.
+// It returns false if the target's processes could not be listed or any collector failed.
+func probe(ctx context.Context, logger log.Logger, client mongodbatlas.Client, options collector.ProcessOptions, registry prometheus.Registerer, projectID, cluster string) bool {
+	processes, httpErr := client.ListProcesses(ctx, projectID)
+	if httpErr != nil {
+		level.Error(logger).Log("msg", "probe failed to list processes", "err", httpErr)
+		return false
+	}
+
+	if cluster != "" {
+		processes = mongodbatlas.FilterProcesses(processes, []string{cluster})
+	}
+
+	success := true
+	for _, process := range processes {
+		processCollector, err := collector.NewProcessCollector(ctx, logger, client, process, options)
+		if err != nil {
+			level.Error(logger).Log("msg", "probe failed to create process collector", "process", process.ID, "err", err)
+			success = false
+			continue
+		}
+
+		if err := registry.Register(processCollector); err != nil {
+			level.Error(logger).Log("msg", "probe failed to register process collector", "process", process.ID, "err", err)
+			success = false
+		}
+	}
+	return success
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"mongodbatlas_exporter/collector"
+	"mongodbatlas_exporter/config"
+	"mongodbatlas_exporter/measurer"
+	m "mongodbatlas_exporter/model"
+	"mongodbatlas_exporter/mongodbatlas"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	atlas "go.mongodb.org/atlas/mongodbatlas"
+)
+
+//probeClient knows the processes of its projects, every process has a single OPCOUNTER_CMD measurement.
+//The embedded Client is nil, calls the probe should not make panic.
+type probeClient struct {
+	mongodbatlas.Client
+	processes map[string][]*atlas.Process
+}
+
+func (c *probeClient) ListProcesses(_ context.Context, projectID string) ([]*atlas.Process, *mongodbatlas.HTTPError) {
+	processes, ok := c.processes[projectID]
+	if !ok {
+		return nil, &mongodbatlas.HTTPError{StatusCode: http.StatusNotFound, Err: errors.New("project not found")}
+	}
+	return processes, nil
+}
+func (c *probeClient) ListDisks(context.Context, *atlas.Process) ([]*atlas.ProcessDisk, *mongodbatlas.HTTPError) {
+	return nil, nil
+}
+func (c *probeClient) ListDatabases(context.Context, *atlas.Process) ([]*atlas.ProcessDatabase, *mongodbatlas.HTTPError) {
+	return nil, nil
+}
+func (c *probeClient) GetProcessMeasurementsMetadata(_ context.Context, p *measurer.Process) *mongodbatlas.HTTPError {
+	metadata := &m.MeasurementMetadata{Name: "OPCOUNTER_CMD", Units: m.SCALAR_PER_SECOND}
+	p.Metadata = map[m.MeasurementID]*m.MeasurementMetadata{metadata.ID(): metadata}
+	return nil
+}
+func (c *probeClient) GetProcessMeasurements(context.Context, measurer.Process) (map[m.MeasurementID]*m.Measurement, error) {
+	value := float32(3)
+	measurement := &m.Measurement{
+		DataPoints: []*atlas.DataPoints{
+			{Timestamp: "2021-03-07T15:46:13Z", Value: &value},
+			{Timestamp: "2021-03-07T15:47:13Z", Value: &value},
+		},
+		Units: m.SCALAR_PER_SECOND,
+	}
+	return map[m.MeasurementID]*m.Measurement{m.NewMeasurementID("OPCOUNTER_CMD", string(m.SCALAR_PER_SECOND)): measurement}, nil
+}
+func (c *probeClient) GetSuggestedIndexes(context.Context, *measurer.Process) ([]*atlas.SuggestedIndex, *mongodbatlas.HTTPError) {
+	return nil, nil
+}
+func (c *probeClient) GetSlowQueries(context.Context, *measurer.Process) ([]*atlas.SlowQuery, *mongodbatlas.HTTPError) {
+	return nil, nil
+}
+func (c *probeClient) AllowedMetricName(string) bool {
+	return true
+}
+func (c *probeClient) MapMeasurement(string) (config.MappedMeasurement, bool) {
+	return config.MappedMeasurement{}, false
+}
+func (c *probeClient) UnitRule(m.UnitEnum) (config.UnitRule, bool) {
+	return config.UnitRule{}, false
+}
+
+func newProbeTestHandler() http.HandlerFunc {
+	client := &probeClient{processes: map[string][]*atlas.Process{
+		"project": {
+			{GroupID: "project", ID: "a-shard-00-00:27017", Hostname: "a-shard-00-00", UserAlias: "clustera-shard-00-00", Port: 27017, TypeName: "REPLICA_PRIMARY"},
+			{GroupID: "project", ID: "b-shard-00-00:27017", Hostname: "b-shard-00-00", UserAlias: "clusterb-shard-00-00", Port: 27017, TypeName: "REPLICA_PRIMARY"},
+		},
+	}}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	return probeHandler(logger, func() mongodbatlas.Client { return client }, collector.ProcessOptions{Counters: true, Timestamps: true})
+}
+
+func probeTestRequest(t *testing.T, handler http.Handler, url string) (int, string) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+	body, err := ioutil.ReadAll(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, string(body)
+}
+
+func TestProbeHandler_unknownProject(t *testing.T) {
+	status, body := probeTestRequest(t, newProbeTestHandler(), "/probe?project=unknown")
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "mongodbatlas_probe_success 0\n")
+	assert.NotContains(t, body, "mongodbatlas_processes_stats_opcounter_cmd")
+}
+
+func TestProbeHandler_missingProject(t *testing.T) {
+	status, _ := probeTestRequest(t, newProbeTestHandler(), "/probe")
+
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+// TestProbeHandler_cluster checks that the cluster parameter narrows the probe down to the processes of one cluster.
+func TestProbeHandler_cluster(t *testing.T) {
+	assert := assert.New(t)
+	handler := newProbeTestHandler()
+
+	status, body := probeTestRequest(t, handler, "/probe?project=project")
+	assert.Equal(http.StatusOK, status)
+	assert.Contains(body, "mongodbatlas_probe_success 1\n")
+	assert.Equal(2, strings.Count(body, "\nmongodbatlas_processes_stats_opcounter_cmd_ratio{"))
+
+	status, body = probeTestRequest(t, handler, "/probe?project=project&cluster=clusterb")
+	assert.Equal(http.StatusOK, status)
+	assert.Contains(body, "mongodbatlas_probe_success 1\n")
+	assert.Equal(1, strings.Count(body, "\nmongodbatlas_processes_stats_opcounter_cmd_ratio{"))
+	assert.Contains(body, `user_alias="clusterb-shard-00-00:27017"`)
+	assert.NotContains(body, `user_alias="clustera-shard-00-00:27017"`)
+}
+
+// TestProbeHandler_statelessOptions checks that the options depending on earlier scrapes are ignored by probes,
+// although the handler was created with counters and timestamps.
+func TestProbeHandler_statelessOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	_, body := probeTestRequest(t, newProbeTestHandler(), "/probe?project=project&cluster=clustera")
+
+	assert.Contains(body, `mongodbatlas_processes_stats_opcounter_cmd_ratio{project_id="project",rs_name="",user_alias="clustera-shard-00-00:27017"} 3`+"\n")
+	assert.NotContains(body, "mongodbatlas_processes_stats_opcounter_cmd_total")
+}
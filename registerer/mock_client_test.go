@@ -1,8 +1,12 @@
 package registerer
 
 import (
+	"context"
+	"errors"
+	"mongodbatlas_exporter/config"
 	"mongodbatlas_exporter/measurer"
 	"mongodbatlas_exporter/model"
+	"time"
 
 	internal "mongodbatlas_exporter/mongodbatlas"
 
@@ -11,23 +15,107 @@ import (
 
 type MockClient struct {
 	processes []*mongodbatlas.Process
+	//failingProjects are projects whose processes can not be listed.
+	failingProjects map[string]bool
+	//processMetadata is the measurement metadata of every process.
+	processMetadata map[model.MeasurementID]*model.MeasurementMetadata
+	//failingMetadata are processes whose metadata can not be fetched, by process ID.
+	failingMetadata map[string]bool
+	//failMetadata delays the failures of failingMetadata until it is closed, if set.
+	failMetadata chan struct{}
 }
 
-func (c *MockClient) GetDiskMeasurements(*measurer.Process, *measurer.Disk) error {
+func (c *MockClient) GetDiskMeasurements(context.Context, *measurer.Process, *measurer.Disk) error {
 	return nil
 }
-func (c *MockClient) GetProcessMeasurements(measurer.Process) (map[model.MeasurementID]*model.Measurement, error) {
+func (c *MockClient) GetProcessMeasurements(context.Context, measurer.Process) (map[model.MeasurementID]*model.Measurement, error) {
 	return nil, nil
 }
-func (c *MockClient) GetDiskMeasurementsMetadata(*measurer.Process, *measurer.Disk) (map[model.MeasurementID]*model.MeasurementMetadata, error) {
+func (c *MockClient) GetDiskMeasurementsMetadata(context.Context, *measurer.Process, *measurer.Disk) (map[model.MeasurementID]*model.MeasurementMetadata, error) {
 	return nil, nil
 }
-func (c *MockClient) GetProcessMeasurementsMetadata(*measurer.Process) *internal.HTTPError {
+func (c *MockClient) GetProcessMeasurementsMetadata(_ context.Context, p *measurer.Process) *internal.HTTPError {
+	if c.failingMetadata[p.ID] {
+		if c.failMetadata != nil {
+			<-c.failMetadata
+		}
+		return &internal.HTTPError{StatusCode: 500, Err: errors.New("failed to get metadata")}
+	}
+	p.Metadata = c.processMetadata
 	return nil
 }
-func (c *MockClient) ListProcesses() ([]*mongodbatlas.Process, *internal.HTTPError) {
-	return c.processes, nil
+
+// ListProjects returns every project that has at least one process.
+func (c *MockClient) ListProjects(context.Context) ([]string, *internal.HTTPError) {
+	seen := make(map[string]bool)
+	projects := []string{}
+	for _, p := range c.processes {
+		if !seen[p.GroupID] {
+			seen[p.GroupID] = true
+			projects = append(projects, p.GroupID)
+		}
+	}
+	return projects, nil
+}
+func (c *MockClient) ListProcesses(_ context.Context, projectID string) ([]*mongodbatlas.Process, *internal.HTTPError) {
+	if c.failingProjects[projectID] {
+		return nil, &internal.HTTPError{StatusCode: 500, Err: errors.New("failed to list processes")}
+	}
+	result := []*mongodbatlas.Process{}
+	for _, p := range c.processes {
+		if p.GroupID == projectID {
+			result = append(result, p)
+		}
+	}
+	return result, nil
 }
-func (c *MockClient) ListDisks(*mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *internal.HTTPError) {
+func (c *MockClient) ListDisks(context.Context, *mongodbatlas.Process) ([]*mongodbatlas.ProcessDisk, *internal.HTTPError) {
 	return nil, nil
 }
+func (c *MockClient) ListClusters(context.Context, string) ([]mongodbatlas.Cluster, *internal.HTTPError) {
+	return nil, nil
+}
+func (c *MockClient) ListDatabases(context.Context, *mongodbatlas.Process) ([]*mongodbatlas.ProcessDatabase, *internal.HTTPError) {
+	return nil, nil
+}
+func (c *MockClient) GetDatabaseMeasurements(context.Context, *measurer.Process, *measurer.Database) error {
+	return nil
+}
+func (c *MockClient) GetDatabaseMeasurementsMetadata(context.Context, *measurer.Process, *measurer.Database) (map[model.MeasurementID]*model.MeasurementMetadata, error) {
+	return nil, nil
+}
+func (c *MockClient) ListAlerts(context.Context, string, string) ([]mongodbatlas.Alert, *internal.HTTPError) {
+	return nil, nil
+}
+
+func (c *MockClient) ListEvents(context.Context, string, time.Time) ([]*mongodbatlas.Event, *internal.HTTPError) {
+	return nil, nil
+}
+
+func (c *MockClient) ListSnapshots(context.Context, string, string) ([]*mongodbatlas.CloudProviderSnapshot, *internal.HTTPError) {
+	return nil, nil
+}
+
+func (c *MockClient) ListRestoreJobs(context.Context, string, string) ([]*mongodbatlas.CloudProviderSnapshotRestoreJob, *internal.HTTPError) {
+	return nil, nil
+}
+
+func (c *MockClient) GetSuggestedIndexes(context.Context, *measurer.Process) ([]*mongodbatlas.SuggestedIndex, *internal.HTTPError) {
+	return nil, nil
+}
+
+func (c *MockClient) GetSlowQueries(context.Context, *measurer.Process) ([]*mongodbatlas.SlowQuery, *internal.HTTPError) {
+	return nil, nil
+}
+
+func (c *MockClient) AllowedMetricName(string) bool {
+	return true
+}
+
+func (c *MockClient) MapMeasurement(string) (config.MappedMeasurement, bool) {
+	return config.MappedMeasurement{}, false
+}
+
+func (c *MockClient) UnitRule(model.UnitEnum) (config.UnitRule, bool) {
+	return config.UnitRule{}, false
+}
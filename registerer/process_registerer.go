@@ -1,9 +1,12 @@
 package registerer
 
 import (
+	"context"
+	"math/rand"
 	"mongodbatlas_exporter/collector"
 	a "mongodbatlas_exporter/mongodbatlas"
 	"strconv"
+	"sync"
 	"time"
 
 	backoff "github.com/cenkalti/backoff/v4"
@@ -11,6 +14,7 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/atlas/mongodbatlas"
 )
 
 var (
@@ -21,39 +25,179 @@ var (
 		Subsystem: subsystem,
 		Name:      "processes_metadatascrape",
 	}, []string{"status"})
+	metadataRefreshes *prometheus.CounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "metadata_refreshes_total",
+		Help:      "Number of refreshes of the measurement metadata of the process collectors by result: unchanged, replaced or failed.",
+	}, []string{"result"})
 )
 
+// PollOptions configure the background polling of process measurements.
+// A zero Interval disables polling, the measurements are then fetched on every scrape.
+type PollOptions struct {
+	Interval time.Duration
+	//MaxStaleness is the age after which polled measurements are no longer reported, 0 reports them forever.
+	MaxStaleness time.Duration
+	//Concurrency is the number of processes polled at the same time.
+	Concurrency int
+	//MetadataInterval is the age after which the measurement metadata of a collector is fetched again,
+	//so measurements Atlas adds or removes, e.g. with a new MongoDB version, are picked up. 0 never refreshes it.
+	MetadataInterval time.Duration
+}
+
 type ProcessRegisterer struct {
-	collectors        map[string]prometheus.Collector
-	reconcileInterval time.Duration
-	client            a.Client
-	logger            log.Logger
+	baseRegisterer
+	poll PollOptions
+	//options are the options of every process collector, Cached and MaxStaleness are set from poll.
+	options collector.ProcessOptions
+	//lastPolls tracks when each collector was polled, by collector key.
+	lastPolls map[string]time.Time
+	//nextRefreshes tracks when the metadata of each collector is due to be fetched again, by collector key.
+	nextRefreshes map[string]time.Time
+
+	//collectors are created in the background, as fetching their metadata may be retried for a minute,
+	//so are the collectors refreshing the metadata of registered ones.
+	//pending holds the client of every creation in flight by collector key, created receives their results
+	//and creations limits how many run at the same time.
+	pending   map[string]a.Client
+	created   chan createdCollector
+	creations chan struct{}
+	//newBackOff returns the retry policy of a creation.
+	newBackOff func() backoff.BackOff
+}
+
+//createdCollector is the result of a collector created in the background.
+type createdCollector struct {
+	key       string
+	client    a.Client
+	collector *collector.Process
+	err       error
+	//refresh is set if the collector refreshes the metadata of the registered one.
+	refresh bool
 }
 
-func NewProcessRegisterer(logger log.Logger, c a.Client, reconcileInterval time.Duration) *ProcessRegisterer {
+func NewProcessRegisterer(logger log.Logger, registry prometheus.Registerer, c a.Client, reconcileInterval time.Duration, poll PollOptions, options collector.ProcessOptions) *ProcessRegisterer {
+	options.Cached = poll.Interval > 0
+	options.MaxStaleness = poll.MaxStaleness
+	if poll.Concurrency < 1 {
+		poll.Concurrency = 1
+	}
 	return &ProcessRegisterer{
-		client:            c,
-		logger:            logger,
-		reconcileInterval: reconcileInterval,
-		collectors:        make(map[string]prometheus.Collector),
+		baseRegisterer: newBaseRegisterer(logger, registry, c, reconcileInterval),
+		poll:           poll,
+		options:        options,
+		lastPolls:      make(map[string]time.Time),
+		nextRefreshes:  make(map[string]time.Time),
+		pending:        make(map[string]a.Client),
+		created:        make(chan createdCollector),
+		creations:      make(chan struct{}, poll.Concurrency),
+		newBackOff: func() backoff.BackOff {
+			b := backoff.NewExponentialBackOff()
+			b.InitialInterval = time.Second * 5
+			b.MaxElapsedTime = 1 * time.Minute
+			return b
+		},
 	}
 }
 
 func (r *ProcessRegisterer) Observe() {
 	//Keep the register up to date.
 	for {
+		r.applyNextClient()
 		r.registerAtlasProcesses()
-		time.Sleep(r.reconcileInterval)
+		if r.poll.Interval > 0 {
+			r.pollUntilReconcile()
+		} else {
+			r.waitUntilReconcile()
+		}
 	}
 }
 
-func (r *ProcessRegisterer) registerAtlasProcesses() {
-	processes, err := r.client.ListProcesses()
+//waitUntilReconcile registers the collectors created in the background
+//until the next reconcile is due or a new client was set.
+func (r *ProcessRegisterer) waitUntilReconcile() {
+	reconcile := time.After(r.reconcileInterval)
+	for {
+		select {
+		case <-reconcile:
+			return
+		case <-r.wakeup:
+			return
+		case created := <-r.created:
+			r.registerCreated(created)
+		}
+	}
+}
 
-	if err != nil {
-		metadataScrapeErrors.With(prometheus.Labels{"status": strconv.FormatInt(int64(err.StatusCode), 10)}).Inc()
+//pollUntilReconcile polls the collectors that are due, including the ones registered just now,
+//until the next reconcile is due or a new client was set.
+func (r *ProcessRegisterer) pollUntilReconcile() {
+	reconcile := time.After(r.reconcileInterval)
+	for {
+		next := r.pollCollectors()
+
+		select {
+		case <-reconcile:
+			return
+		case <-r.wakeup:
+			return
+		case created := <-r.created:
+			//the new collector is polled right away.
+			r.registerCreated(created)
+		case <-time.After(time.Until(next)):
+		}
+	}
+}
+
+//pollCollectors polls every collector whose last poll is older than the poll interval
+//and returns when the next collector is due.
+func (r *ProcessRegisterer) pollCollectors() time.Time {
+	now := time.Now()
+	next := now.Add(r.poll.Interval)
+
+	for key := range r.lastPolls {
+		if _, ok := r.collectors[key]; !ok {
+			delete(r.lastPolls, key)
+		}
+	}
+
+	var due []collector.Poller
+	for key, c := range r.collectors {
+		poller, ok := c.(collector.Poller)
+		if !ok {
+			continue
+		}
+		if lastPoll, ok := r.lastPolls[key]; ok && now.Sub(lastPoll) < r.poll.Interval {
+			if lastPoll.Add(r.poll.Interval).Before(next) {
+				next = lastPoll.Add(r.poll.Interval)
+			}
+			continue
+		}
+		r.lastPolls[key] = now
+		due = append(due, poller)
 	}
 
+	//polls only read the collectors' own state, so they can run in parallel.
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, r.poll.Concurrency)
+	for _, poller := range due {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(poller collector.Poller) {
+			defer wg.Done()
+			poller.Poll()
+			<-sem
+		}(poller)
+	}
+	wg.Wait()
+
+	return next
+}
+
+func (r *ProcessRegisterer) registerAtlasProcesses() {
+	processes, complete := r.listAtlasProcesses()
+
 	currentCollectorKeys := make(map[string]bool, len(processes)) //tracks the existing processes for pruning.
 	for _, process := range processes {
 		collectorKey := process.ID + process.TypeName
@@ -61,10 +205,12 @@ func (r *ProcessRegisterer) registerAtlasProcesses() {
 	}
 
 	//unregister excess collectors
+	//when the processes of some project could not be listed we can not tell
+	//which of its collectors are gone, so pruning waits for the next reconcile.
 	for key := range r.collectors {
 		//if the collector is no longer needed
-		if _, ok := currentCollectorKeys[key]; !ok {
-			prometheus.Unregister(r.collectors[key])
+		if _, ok := currentCollectorKeys[key]; !ok && complete {
+			r.registry.Unregister(r.collectors[key])
 			delete(r.collectors, key)
 		}
 	}
@@ -72,28 +218,186 @@ func (r *ProcessRegisterer) registerAtlasProcesses() {
 	for _, process := range processes {
 		//the way to check for no longer existing hashes is to make a map[ID+TypeName]
 		//out of the current list and set difference it to this map.
+		collectorKey := process.ID + process.TypeName
+		if _, ok := r.collectors[collectorKey]; !ok && r.pending[collectorKey] != r.client {
+			r.pending[collectorKey] = r.client
+			go r.createCollector(collectorKey, r.client, process, false)
+		}
+	}
+
+	r.refreshMetadata(processes)
+}
+
+//createCollector creates the collector of a process, retrying for up to a minute,
+//and hands it to the observation loop, which is the only place collectors are registered.
+func (r *ProcessRegisterer) createCollector(key string, client a.Client, process *mongodbatlas.Process, refresh bool) {
+	r.creations <- struct{}{}
+	created := createdCollector{key: key, client: client, refresh: refresh}
+	created.err = backoff.Retry(func() error {
+		var err error
+		created.collector, err = collector.NewProcessCollector(context.Background(), r.logger, client, process, r.options)
+		return err
+	}, r.newBackOff())
+	<-r.creations
+
+	r.created <- created
+}
+
+//registerCreated registers a collector created in the background.
+//Collectors created with a replaced client are dropped, failed ones are created again on the next reconcile.
+func (r *ProcessRegisterer) registerCreated(created createdCollector) {
+	if r.pending[created.key] == created.client {
+		delete(r.pending, created.key)
+	}
+	if created.refresh {
+		r.replaceCollector(created)
+		return
+	}
+	if created.err != nil {
+		level.Debug(r.logger).Log("msg", "failed collector instantation", "err", created.err)
+		return
+	}
+	if _, ok := r.collectors[created.key]; ok || created.client != r.client {
+		return
+	}
+
+	if err := r.registry.Register(created.collector); err != nil {
+		level.Warn(r.logger).Log("msg", "failed to register collector", "collector", created.key, "err", err)
+		return
+	}
+	r.collectors[created.key] = created.collector
+	//the collectors discovered together, e.g. on startup, are refreshed at random points of the second half
+	//of the interval, so their refreshes are spread instead of all running at once.
+	if r.poll.MetadataInterval > 0 {
+		delay := r.poll.MetadataInterval/2 + time.Duration(rand.Int63n(int64(r.poll.MetadataInterval/2)+1))
+		r.nextRefreshes[created.key] = time.Now().Add(delay)
+	}
+}
+
+//refreshMetadata starts refreshing the collectors whose metadata is due in the background,
+//where they share the concurrency limit of the creations.
+func (r *ProcessRegisterer) refreshMetadata(processes []*mongodbatlas.Process) {
+	for key := range r.nextRefreshes {
+		if _, ok := r.collectors[key]; !ok {
+			delete(r.nextRefreshes, key)
+		}
+	}
+	if r.poll.MetadataInterval <= 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, process := range processes {
 		collectorKey := process.ID + process.TypeName
 		if _, ok := r.collectors[collectorKey]; !ok {
-			b := backoff.NewExponentialBackOff()
+			continue
+		}
+		if nextRefresh, ok := r.nextRefreshes[collectorKey]; ok && now.Before(nextRefresh) {
+			continue
+		}
+		if r.pending[collectorKey] == r.client {
+			continue
+		}
+		r.pending[collectorKey] = r.client
+		go r.createCollector(collectorKey, r.client, process, true)
+	}
+}
 
-			b.InitialInterval = time.Second * 5
-			b.MaxElapsedTime = 1 * time.Minute
+//replaceCollector replaces a registered collector with the one refreshing its metadata.
+//It is only replaced if its descriptions changed, the new one takes over its state
+//and is registered instead of it, so scrapes never see both.
+func (r *ProcessRegisterer) replaceCollector(refreshed createdCollector) {
+	current, ok := r.collectors[refreshed.key]
+	if !ok || refreshed.client != r.client {
+		//the collector was pruned or belongs to a replaced client.
+		return
+	}
+	r.nextRefreshes[refreshed.key] = time.Now().Add(r.poll.MetadataInterval)
 
-			err := backoff.Retry(func() error {
-				collector, err := collector.NewProcessCollector(r.logger, r.client, process)
-				if err != nil {
-					return err
-				}
-				r.collectors[collectorKey] = collector
-				prometheus.MustRegister(collector)
-				return nil
-			}, b)
-
-			if err != nil {
-				level.Debug(r.logger).Log("msg", "failed collector instantation", "err", err)
-			}
+	if refreshed.err != nil {
+		//the current collector keeps its metadata until the next refresh.
+		metadataRefreshes.WithLabelValues("failed").Inc()
+		level.Debug(r.logger).Log("msg", "failed to refresh metadata", "collector", refreshed.key, "err", refreshed.err)
+		return
+	}
+	if sameDescs(current, refreshed.collector) {
+		metadataRefreshes.WithLabelValues("unchanged").Inc()
+		return
+	}
+
+	if previous, ok := current.(*collector.Process); ok {
+		refreshed.collector.Inherit(previous)
+	}
+	//the registry identifies a collector by its descriptions, so the current one is unregistered
+	//before the refreshed one with overlapping descriptions can be registered.
+	r.registry.Unregister(current)
+	if err := r.registry.Register(refreshed.collector); err != nil {
+		metadataRefreshes.WithLabelValues("failed").Inc()
+		level.Warn(r.logger).Log("msg", "failed to register refreshed collector, keeping the current one", "collector", refreshed.key, "err", err)
+		if err := r.registry.Register(current); err != nil {
+			//a collector missing from the registry must not be tracked, the next reconcile creates it again.
+			level.Error(r.logger).Log("msg", "failed to register the current collector again, dropping it", "collector", refreshed.key, "err", err)
+			delete(r.collectors, refreshed.key)
+			delete(r.lastPolls, refreshed.key)
+			delete(r.nextRefreshes, refreshed.key)
+		}
+		return
+	}
+	r.collectors[refreshed.key] = refreshed.collector
+	//without cached measurements the refreshed collector is polled right away.
+	delete(r.lastPolls, refreshed.key)
+	metadataRefreshes.WithLabelValues("replaced").Inc()
+	level.Info(r.logger).Log("msg", "refreshed metadata", "collector", refreshed.key)
+}
+
+//sameDescs reports whether two collectors describe the same metrics.
+func sameDescs(x, y prometheus.Collector) bool {
+	descs := func(c prometheus.Collector) map[string]bool {
+		ch := make(chan *prometheus.Desc)
+		go func() {
+			c.Describe(ch)
+			close(ch)
+		}()
+		result := make(map[string]bool)
+		for desc := range ch {
+			result[desc.String()] = true
+		}
+		return result
+	}
+
+	descsX, descsY := descs(x), descs(y)
+	if len(descsX) != len(descsY) {
+		return false
+	}
+	for desc := range descsX {
+		if !descsY[desc] {
+			return false
+		}
+	}
+	return true
+}
+
+// listAtlasProcesses returns the processes of every project the client knows about.
+// complete is false if the projects or the processes of any project could not be listed.
+func (r *ProcessRegisterer) listAtlasProcesses() (processes []*mongodbatlas.Process, complete bool) {
+	projectIDs, err := r.client.ListProjects(context.Background())
+
+	if err != nil {
+		metadataScrapeErrors.With(prometheus.Labels{"status": strconv.FormatInt(int64(err.StatusCode), 10)}).Inc()
+		return nil, false
+	}
+
+	complete = true
+	for _, projectID := range projectIDs {
+		projectProcesses, err := r.client.ListProcesses(context.Background(), projectID)
 
+		if err != nil {
+			metadataScrapeErrors.With(prometheus.Labels{"status": strconv.FormatInt(int64(err.StatusCode), 10)}).Inc()
+			complete = false
+			continue
 		}
+		processes = append(processes, projectProcesses...)
 	}
 
+	return processes, complete
 }
@@ -1,11 +1,17 @@
 package registerer
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"mongodbatlas_exporter/collector"
+	"mongodbatlas_exporter/model"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	backoff "github.com/cenkalti/backoff/v4"
 	"github.com/go-kit/log"
 	"github.com/onsi/gomega"
 	"github.com/prometheus/client_golang/prometheus"
@@ -43,7 +49,7 @@ func TestProcesRegisterer(t *testing.T) {
 		processes: expectedProcesses,
 	}
 
-	reg := NewProcessRegisterer(logger, &client, time.Millisecond)
+	reg := NewProcessRegisterer(logger, collector.NewRegistry(), &client, time.Millisecond, PollOptions{}, collector.ProcessOptions{})
 
 	expectedProcessesMap := make(map[string]*mongodbatlas.Process)
 
@@ -52,7 +58,7 @@ func TestProcesRegisterer(t *testing.T) {
 		expectedProcessesMap[p.ID+p.TypeName] = p
 	}
 
-	reg.registerAtlasProcesses()
+	reconcile(reg)
 	g.Expect(len(reg.collectors)).Should(gomega.Equal(len(expectedProcessesMap)))
 	g.Expect(assertCollectorMapInSync(g, expectedProcessesMap, reg.collectors)).Should(gomega.Succeed())
 
@@ -61,7 +67,7 @@ func TestProcesRegisterer(t *testing.T) {
 	b := expectedProcesses[1]
 	delete(expectedProcessesMap, b.ID+b.TypeName)
 
-	reg.registerAtlasProcesses()
+	reconcile(reg)
 	g.Expect(len(reg.collectors)).Should(gomega.Equal(len(expectedProcessesMap)))
 	g.Expect(assertCollectorMapInSync(g, expectedProcessesMap, reg.collectors)).Should(gomega.Succeed())
 
@@ -69,7 +75,7 @@ func TestProcesRegisterer(t *testing.T) {
 	client.processes = expectedProcesses
 	expectedProcessesMap[b.ID+b.TypeName] = b
 
-	reg.registerAtlasProcesses()
+	reconcile(reg)
 	g.Expect(len(reg.collectors)).Should(gomega.Equal(len(expectedProcessesMap)))
 	g.Expect(assertCollectorMapInSync(g, expectedProcessesMap, reg.collectors)).Should(gomega.Succeed())
 
@@ -82,7 +88,7 @@ func TestProcesRegisterer(t *testing.T) {
 		p := expectedProcesses[i]
 		expectedProcessesMap[p.ID+p.TypeName] = p
 	}
-	reg.registerAtlasProcesses()
+	reconcile(reg)
 	g.Expect(len(reg.collectors)).Should(gomega.Equal(len(expectedProcessesMap)))
 	g.Expect(assertCollectorMapInSync(g, expectedProcessesMap, reg.collectors)).Should(gomega.Succeed())
 }
@@ -104,3 +110,306 @@ func assertCollectorMapInSync(g *gomega.GomegaWithT, expected map[string]*mongod
 	}
 	return nil
 }
+
+//TestProcessRegistererPartialFailure tests that the collectors of a project
+//are kept when its processes can not be listed, while the other projects
+//are still reconciled.
+func TestProcessRegistererPartialFailure(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	processes := []*mongodbatlas.Process{
+		{
+			GroupID:  "project-a",
+			ID:       "hosta:27017",
+			TypeName: "REPLICA_PRIMARY",
+		},
+		{
+			GroupID:  "project-b",
+			ID:       "hostb:27017",
+			TypeName: "REPLICA_PRIMARY",
+		},
+	}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	client := MockClient{
+		processes: processes,
+	}
+
+	reg := NewProcessRegisterer(logger, collector.NewRegistry(), &client, time.Millisecond, PollOptions{}, collector.ProcessOptions{})
+	reconcile(reg)
+	g.Expect(len(reg.collectors)).Should(gomega.Equal(2))
+
+	//project-b fails, its collector must survive.
+	client.failingProjects = map[string]bool{"project-b": true}
+	reconcile(reg)
+	g.Expect(len(reg.collectors)).Should(gomega.Equal(2))
+
+	//project-b recovers without its process, now it can be pruned.
+	client.failingProjects = nil
+	client.processes = processes[0:1]
+	reconcile(reg)
+	g.Expect(len(reg.collectors)).Should(gomega.Equal(1))
+	_, ok := reg.collectors["hosta:27017REPLICA_PRIMARY"]
+	g.Expect(ok).Should(gomega.BeTrue())
+}
+
+//TestProcessRegistererSetClient tests that a new client replaces all
+//collectors, e.g. after the configuration was reloaded.
+func TestProcessRegistererSetClient(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	oldClient := MockClient{
+		processes: []*mongodbatlas.Process{
+			{GroupID: "old-project", ID: "old:27017", TypeName: "REPLICA_PRIMARY"},
+		},
+	}
+	newClient := MockClient{
+		processes: []*mongodbatlas.Process{
+			{GroupID: "new-project", ID: "new:27017", TypeName: "REPLICA_PRIMARY"},
+		},
+	}
+
+	reg := NewProcessRegisterer(logger, collector.NewRegistry(), &oldClient, time.Millisecond, PollOptions{}, collector.ProcessOptions{})
+	reconcile(reg)
+	g.Expect(reg.collectors).Should(gomega.HaveKey("old:27017REPLICA_PRIMARY"))
+
+	reg.SetClient(&newClient)
+	g.Expect(reg.wakeup).Should(gomega.HaveLen(1))
+
+	reg.applyNextClient()
+	g.Expect(reg.collectors).Should(gomega.BeEmpty())
+
+	reconcile(reg)
+	g.Expect(reg.collectors).Should(gomega.HaveLen(1))
+	g.Expect(reg.collectors).Should(gomega.HaveKey("new:27017REPLICA_PRIMARY"))
+}
+
+type mockPoller struct {
+	mockProjectCollector
+	polls int32
+}
+
+func (c *mockPoller) Poll() {
+	atomic.AddInt32(&c.polls, 1)
+}
+
+//TestProcessRegistererPoll tests that new collectors are polled right away
+//and known ones only once the poll interval passed.
+func TestProcessRegistererPoll(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	reg := NewProcessRegisterer(logger, collector.NewRegistry(), &MockClient{}, time.Hour, PollOptions{Interval: time.Hour, Concurrency: 2}, collector.ProcessOptions{})
+
+	a, b := &mockPoller{}, &mockPoller{}
+	reg.collectors["a"] = a
+	next := reg.pollCollectors()
+	g.Expect(atomic.LoadInt32(&a.polls)).Should(gomega.Equal(int32(1)))
+	g.Expect(next).Should(gomega.BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+
+	reg.collectors["b"] = b
+	reg.pollCollectors()
+	g.Expect(atomic.LoadInt32(&a.polls)).Should(gomega.Equal(int32(1)))
+	g.Expect(atomic.LoadInt32(&b.polls)).Should(gomega.Equal(int32(1)))
+
+	reg.lastPolls["a"] = time.Now().Add(-2 * time.Hour)
+	delete(reg.collectors, "b")
+	reg.pollCollectors()
+	g.Expect(atomic.LoadInt32(&a.polls)).Should(gomega.Equal(int32(2)))
+	g.Expect(reg.lastPolls).ShouldNot(gomega.HaveKey("b"))
+}
+
+//TestProcessRegistererRefreshMetadata tests that a collector is replaced
+//once its metadata is due and Atlas reports different measurements.
+func TestProcessRegistererRefreshMetadata(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	connections := &model.MeasurementMetadata{Name: "CONNECTIONS", Units: model.SCALAR}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	client := MockClient{
+		processes: []*mongodbatlas.Process{
+			{GroupID: "project", ID: "host:27017", TypeName: "REPLICA_PRIMARY"},
+		},
+		processMetadata: map[model.MeasurementID]*model.MeasurementMetadata{connections.ID(): connections},
+	}
+	key := "host:27017REPLICA_PRIMARY"
+
+	reg := NewProcessRegisterer(logger, collector.NewRegistry(), &client, time.Millisecond, PollOptions{MetadataInterval: time.Hour}, collector.ProcessOptions{})
+	reconcile(reg)
+	registered := reg.collectors[key]
+	g.Expect(registered).ShouldNot(gomega.BeNil())
+	//the first refresh is due in the second half of the interval.
+	g.Expect(reg.nextRefreshes[key]).Should(gomega.BeTemporally(">=", time.Now().Add(29*time.Minute)))
+	g.Expect(reg.nextRefreshes[key]).Should(gomega.BeTemporally("<=", time.Now().Add(time.Hour)))
+
+	//the metadata is not due yet.
+	cursors := &model.MeasurementMetadata{Name: "CURSORS_TOTAL_OPEN", Units: model.SCALAR}
+	client.processMetadata = map[model.MeasurementID]*model.MeasurementMetadata{connections.ID(): connections, cursors.ID(): cursors}
+	reconcile(reg)
+	g.Expect(reg.collectors[key]).Should(gomega.BeIdenticalTo(registered))
+
+	//unchanged metadata keeps the collector.
+	client.processMetadata = map[model.MeasurementID]*model.MeasurementMetadata{connections.ID(): connections}
+	reg.nextRefreshes[key] = time.Now()
+	reconcile(reg)
+	g.Expect(reg.collectors[key]).Should(gomega.BeIdenticalTo(registered))
+
+	//a new measurement replaces the collector in the registry.
+	client.processMetadata = map[model.MeasurementID]*model.MeasurementMetadata{connections.ID(): connections, cursors.ID(): cursors}
+	reg.nextRefreshes[key] = time.Now()
+	reconcile(reg)
+	refreshed := reg.collectors[key]
+	g.Expect(refreshed).ShouldNot(gomega.BeIdenticalTo(registered))
+	g.Expect(reg.registry.Unregister(registered)).Should(gomega.BeFalse())
+	g.Expect(reg.registry.Unregister(refreshed)).Should(gomega.BeTrue())
+}
+
+//TestProcessRegistererFailingProcess tests that a process whose collector can not be created
+//delays neither the other processes nor the reconcile, and is created again on the next reconcile.
+func TestProcessRegistererFailingProcess(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	client := MockClient{
+		processes: []*mongodbatlas.Process{
+			{GroupID: "project", ID: "good:27017", UserAlias: "good", TypeName: "REPLICA_PRIMARY"},
+			{GroupID: "project", ID: "failing:27017", UserAlias: "failing", TypeName: "REPLICA_PRIMARY"},
+		},
+		failingMetadata: map[string]bool{"failing:27017": true},
+	}
+
+	reg := NewProcessRegisterer(logger, collector.NewRegistry(), &client, time.Millisecond, PollOptions{Concurrency: 2}, collector.ProcessOptions{})
+	reg.newBackOff = func() backoff.BackOff {
+		return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 0)
+	}
+	//the creation of the failing process hangs until it is released.
+	client.failMetadata = make(chan struct{})
+
+	reg.registerAtlasProcesses()
+	reg.registerCreated(<-reg.created)
+	g.Expect(reg.collectors).Should(gomega.HaveKey("good:27017REPLICA_PRIMARY"))
+	g.Expect(reg.pending).Should(gomega.HaveKey("failing:27017REPLICA_PRIMARY"))
+
+	close(client.failMetadata)
+	reg.registerCreated(<-reg.created)
+	g.Expect(reg.pending).Should(gomega.BeEmpty())
+	g.Expect(reg.collectors).Should(gomega.HaveLen(1))
+
+	//the failed process is retried.
+	client.failingMetadata = nil
+	reconcile(reg)
+	g.Expect(reg.collectors).Should(gomega.HaveLen(2))
+}
+
+//TestProcessRegistererRegisterError tests that a collector the registry rejects is skipped instead of panicking.
+func TestProcessRegistererRegisterError(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	process := &mongodbatlas.Process{GroupID: "project", ID: "host:27017", UserAlias: "conflict", TypeName: "REPLICA_PRIMARY"}
+	client := MockClient{processes: []*mongodbatlas.Process{process}}
+
+	//a collector of the same process registered elsewhere conflicts with the one of the registerer.
+	conflict, err := collector.NewProcessCollector(context.Background(), logger, &client, process, collector.ProcessOptions{})
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	registry := collector.NewRegistry()
+	g.Expect(registry.Register(conflict)).Should(gomega.Succeed())
+
+	reg := NewProcessRegisterer(logger, registry, &client, time.Millisecond, PollOptions{}, collector.ProcessOptions{})
+
+	g.Expect(func() { reconcile(reg) }).ShouldNot(gomega.Panic())
+	g.Expect(reg.collectors).Should(gomega.BeEmpty())
+}
+
+//TestProcessRegistererRefreshInBackground tests that a hanging refresh blocks neither the reconcile
+//nor the current collector, which is kept when the refresh fails.
+func TestProcessRegistererRefreshInBackground(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	client := MockClient{
+		processes: []*mongodbatlas.Process{
+			{GroupID: "project", ID: "host:27017", TypeName: "REPLICA_PRIMARY"},
+		},
+	}
+	key := "host:27017REPLICA_PRIMARY"
+
+	reg := NewProcessRegisterer(logger, collector.NewRegistry(), &client, time.Millisecond, PollOptions{MetadataInterval: time.Hour}, collector.ProcessOptions{})
+	reconcile(reg)
+	registered := reg.collectors[key]
+
+	//the refresh hangs until it is released.
+	client.failingMetadata = map[string]bool{"host:27017": true}
+	client.failMetadata = make(chan struct{})
+	reg.nextRefreshes[key] = time.Now()
+	reg.registerAtlasProcesses()
+	g.Expect(reg.pending).Should(gomega.HaveKey(key))
+	//a refresh in flight is not started again.
+	reg.registerAtlasProcesses()
+	g.Expect(reg.collectors[key]).Should(gomega.BeIdenticalTo(registered))
+
+	close(client.failMetadata)
+	reg.registerCreated(<-reg.created)
+	g.Expect(reg.pending).Should(gomega.BeEmpty())
+	g.Expect(reg.collectors[key]).Should(gomega.BeIdenticalTo(registered))
+	g.Expect(reg.nextRefreshes[key]).Should(gomega.BeTemporally(">", time.Now().Add(59*time.Minute)))
+}
+
+//rejectingRegistry rejects every registration while reject is set.
+type rejectingRegistry struct {
+	prometheus.Registerer
+	reject bool
+}
+
+func (r *rejectingRegistry) Register(c prometheus.Collector) error {
+	if r.reject {
+		return errors.New("rejected")
+	}
+	return r.Registerer.Register(c)
+}
+
+//TestProcessRegistererRefreshRollbackError tests that a collector the registry rejects again after a failed
+//refresh is no longer tracked, so the next reconcile creates it again instead of it silently missing.
+func TestProcessRegistererRefreshRollbackError(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	connections := &model.MeasurementMetadata{Name: "CONNECTIONS", Units: model.SCALAR}
+	cursors := &model.MeasurementMetadata{Name: "CURSORS_TOTAL_OPEN", Units: model.SCALAR}
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	client := MockClient{
+		processes: []*mongodbatlas.Process{
+			{GroupID: "project", ID: "host:27017", TypeName: "REPLICA_PRIMARY"},
+		},
+		processMetadata: map[model.MeasurementID]*model.MeasurementMetadata{connections.ID(): connections},
+	}
+	key := "host:27017REPLICA_PRIMARY"
+
+	registry := &rejectingRegistry{Registerer: collector.NewRegistry()}
+	reg := NewProcessRegisterer(logger, registry, &client, time.Millisecond, PollOptions{MetadataInterval: time.Hour}, collector.ProcessOptions{})
+	reconcile(reg)
+	registered := reg.collectors[key]
+	g.Expect(registered).ShouldNot(gomega.BeNil())
+
+	client.processMetadata = map[model.MeasurementID]*model.MeasurementMetadata{connections.ID(): connections, cursors.ID(): cursors}
+	reg.nextRefreshes[key] = time.Now()
+	registry.reject = true
+	reconcile(reg)
+	g.Expect(reg.collectors).ShouldNot(gomega.HaveKey(key))
+	g.Expect(reg.nextRefreshes).ShouldNot(gomega.HaveKey(key))
+
+	registry.reject = false
+	reconcile(reg)
+	g.Expect(reg.collectors).Should(gomega.HaveKey(key))
+	g.Expect(reg.collectors[key]).ShouldNot(gomega.BeIdenticalTo(registered))
+}
+
+//reconcile runs a reconcile of the registerer and registers the collectors it creates in the background.
+func reconcile(reg *ProcessRegisterer) {
+	reg.newBackOff = func() backoff.BackOff {
+		return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 0)
+	}
+	reg.registerAtlasProcesses()
+	for len(reg.pending) > 0 {
+		reg.registerCreated(<-reg.created)
+	}
+}
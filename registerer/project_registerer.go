@@ -0,0 +1,120 @@
+package registerer
+
+import (
+	"context"
+	"mongodbatlas_exporter/collector"
+	a "mongodbatlas_exporter/mongodbatlas"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	projectsScrapeErrors *prometheus.CounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "projects_metadatascrape",
+	}, []string{"status"})
+)
+
+// ProjectCollectorFactory creates a collector for resources that exist once per project,
+// such as the list of clusters.
+type ProjectCollectorFactory func(logger log.Logger, client a.Client, projectID string) (prometheus.Collector, error)
+
+// NewProjectCollectorFactories returns the available project collectors by name.
+// eventMarks keeps the high-water marks of the events collector, fetchTimeout is the deadline
+// of the requests of collectors that are not collected with the context of a scrape.
+func NewProjectCollectorFactories(eventMarks *collector.EventMarks, fetchTimeout time.Duration) map[string]ProjectCollectorFactory {
+	return map[string]ProjectCollectorFactory{
+		"clusters": func(logger log.Logger, client a.Client, projectID string) (prometheus.Collector, error) {
+			return collector.NewClusterCollector(logger, client, projectID, fetchTimeout)
+		},
+		"alerts": func(logger log.Logger, client a.Client, projectID string) (prometheus.Collector, error) {
+			return collector.NewAlertCollector(logger, client, projectID, fetchTimeout)
+		},
+		"backup": func(logger log.Logger, client a.Client, projectID string) (prometheus.Collector, error) {
+			return collector.NewBackupCollector(logger, client, projectID, fetchTimeout)
+		},
+		"events": func(logger log.Logger, client a.Client, projectID string) (prometheus.Collector, error) {
+			return collector.NewEventCollector(logger, client, projectID, eventMarks, fetchTimeout)
+		},
+	}
+}
+
+// ProjectRegisterer registers one collector per project and factory.
+type ProjectRegisterer struct {
+	baseRegisterer
+	factories map[string]ProjectCollectorFactory
+}
+
+func NewProjectRegisterer(logger log.Logger, registry prometheus.Registerer, c a.Client, reconcileInterval time.Duration, factories map[string]ProjectCollectorFactory) *ProjectRegisterer {
+	return &ProjectRegisterer{
+		baseRegisterer: newBaseRegisterer(logger, registry, c, reconcileInterval),
+		factories:      factories,
+	}
+}
+
+func (r *ProjectRegisterer) Observe() {
+	//Keep the register up to date.
+	for {
+		r.applyNextClient()
+		r.registerAtlasProjects()
+		r.wait()
+	}
+}
+
+func (r *ProjectRegisterer) registerAtlasProjects() {
+	projectIDs, err := r.client.ListProjects(context.Background())
+
+	if err != nil {
+		projectsScrapeErrors.With(prometheus.Labels{"status": strconv.FormatInt(int64(err.StatusCode), 10)}).Inc()
+		return
+	}
+
+	currentCollectorKeys := make(map[string]bool, len(projectIDs)*len(r.factories))
+	for _, projectID := range projectIDs {
+		for name := range r.factories {
+			currentCollectorKeys[projectID+"/"+name] = true
+		}
+	}
+
+	//unregister the collectors of projects that are gone
+	for key := range r.collectors {
+		if _, ok := currentCollectorKeys[key]; !ok {
+			r.registry.Unregister(r.collectors[key])
+			delete(r.collectors, key)
+		}
+	}
+
+	for _, projectID := range projectIDs {
+		for name, factory := range r.factories {
+			collectorKey := projectID + "/" + name
+			if _, ok := r.collectors[collectorKey]; ok {
+				continue
+			}
+
+			collector, err := factory(r.logger, r.client, projectID)
+			if err != nil {
+				level.Debug(r.logger).Log("msg", "failed collector instantation", "collector", name, "project", projectID, "err", err)
+				continue
+			}
+
+			if err := r.registry.Register(collector); err != nil {
+				level.Error(r.logger).Log("msg", "failed to register collector", "collector", name, "project", projectID, "err", err)
+				continue
+			}
+			r.collectors[collectorKey] = collector
+		}
+	}
+
+	//collectors that are too expensive to query on every scrape are updated at the reconcile cadence.
+	for _, c := range r.collectors {
+		if poller, ok := c.(collector.Poller); ok {
+			poller.Poll()
+		}
+	}
+}
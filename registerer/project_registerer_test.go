@@ -0,0 +1,59 @@
+package registerer
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"mongodbatlas_exporter/collector"
+	a "mongodbatlas_exporter/mongodbatlas"
+
+	"github.com/go-kit/kit/log"
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/atlas/mongodbatlas"
+)
+
+type mockProjectCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c *mockProjectCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *mockProjectCollector) Collect(ch chan<- prometheus.Metric) {}
+
+//TestProjectRegisterer tests that every project has one collector per factory
+//and that the collectors of projects that disappear are removed.
+func TestProjectRegisterer(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	client := MockClient{
+		processes: []*mongodbatlas.Process{
+			{GroupID: "project-a"},
+			{GroupID: "project-b"},
+		},
+	}
+	factories := map[string]ProjectCollectorFactory{
+		"mock": func(_ log.Logger, _ a.Client, projectID string) (prometheus.Collector, error) {
+			return &mockProjectCollector{
+				desc: prometheus.NewDesc("mock_project", "mock", nil, prometheus.Labels{"project_id": projectID}),
+			}, nil
+		},
+	}
+
+	reg := NewProjectRegisterer(logger, collector.NewRegistry(), &client, time.Millisecond, factories)
+
+	reg.registerAtlasProjects()
+	g.Expect(reg.collectors).Should(gomega.HaveLen(2))
+	g.Expect(reg.collectors).Should(gomega.HaveKey("project-a/mock"))
+	g.Expect(reg.collectors).Should(gomega.HaveKey("project-b/mock"))
+
+	//remove project-b
+	client.processes = client.processes[0:1]
+	reg.registerAtlasProjects()
+	g.Expect(reg.collectors).Should(gomega.HaveLen(1))
+	g.Expect(reg.collectors).Should(gomega.HaveKey("project-a/mock"))
+}
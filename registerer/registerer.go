@@ -1,6 +1,13 @@
 package registerer
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	a "mongodbatlas_exporter/mongodbatlas"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 type Registerer interface {
 	//Observe starts the Registerer observation loop to track resources and collectors.
@@ -8,3 +15,69 @@ type Registerer interface {
 	//Collectors returns the map of collectors?
 	Collectors() map[string]prometheus.Collector
 }
+
+//baseRegisterer holds the collectors and the client shared by all registerers.
+type baseRegisterer struct {
+	//registry serves the collectors, usually a collector.Registry shared by all registerers.
+	registry          prometheus.Registerer
+	collectors        map[string]prometheus.Collector
+	reconcileInterval time.Duration
+	client            a.Client
+	logger            log.Logger
+
+	//nextClient is handed over by SetClient and picked up by the observation loop,
+	//which is the only place collectors are touched.
+	mu         sync.Mutex
+	nextClient a.Client
+	wakeup     chan struct{}
+}
+
+func newBaseRegisterer(logger log.Logger, registry prometheus.Registerer, c a.Client, reconcileInterval time.Duration) baseRegisterer {
+	return baseRegisterer{
+		registry:          registry,
+		client:            c,
+		logger:            logger,
+		reconcileInterval: reconcileInterval,
+		collectors:        make(map[string]prometheus.Collector),
+		wakeup:            make(chan struct{}, 1),
+	}
+}
+
+// SetClient replaces the client, e.g. after the configuration was reloaded.
+// All collectors are recreated with the new client on the next reconcile, which starts immediately.
+func (r *baseRegisterer) SetClient(c a.Client) {
+	r.mu.Lock()
+	r.nextClient = c
+	r.mu.Unlock()
+
+	select {
+	case r.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (r *baseRegisterer) applyNextClient() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.nextClient == nil {
+		return
+	}
+
+	//collectors keep a reference to the client they were created with,
+	//the credentials or filters of the old one might be outdated.
+	for key := range r.collectors {
+		r.registry.Unregister(r.collectors[key])
+		delete(r.collectors, key)
+	}
+	r.client = r.nextClient
+	r.nextClient = nil
+}
+
+//wait blocks until the next reconcile is due or a new client was set.
+func (r *baseRegisterer) wait() {
+	select {
+	case <-time.After(r.reconcileInterval):
+	case <-r.wakeup:
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"mongodbatlas_exporter/config"
+	"mongodbatlas_exporter/mongodbatlas"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	configReloadSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mongodbatlas_exporter_config_last_reload_successful",
+		Help: "Was the last configuration reload successful.",
+	})
+	configReloadSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "mongodbatlas_exporter_config_last_reload_success_timestamp_seconds",
+		Help: "Timestamp of the last successful configuration reload.",
+	})
+)
+
+// reloader owns the current client and replaces it whenever the configuration file is reloaded.
+type reloader struct {
+	logger log.Logger
+	//reloading serializes the reloads of SIGHUP and POST /-/reload from loading the configuration
+	//until the client is handed over, so the last loaded configuration is the one in use.
+	reloading   sync.Mutex
+	mu          sync.RWMutex
+	client      mongodbatlas.Client
+	registerers []clientSetter
+}
+
+// clientSetter is implemented by the registerers.
+type clientSetter interface {
+	SetClient(mongodbatlas.Client)
+}
+
+// Client returns the client built from the latest valid configuration.
+func (r *reloader) Client() mongodbatlas.Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.client
+}
+
+// reload rereads the configuration file. An invalid configuration keeps the current client.
+func (r *reloader) reload() error {
+	if *configFile == "" {
+		return errors.New("no configuration file to reload, see --config.file")
+	}
+
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+	client, err := newClient(r.logger)
+	if err != nil {
+		configReloadSuccess.Set(0)
+		level.Error(r.logger).Log("msg", "failed to reload configuration", "file", *configFile, "err", err)
+		return err
+	}
+
+	client.ApplyRateLimits()
+	r.mu.Lock()
+	r.client = client
+	r.mu.Unlock()
+	for _, registerer := range r.registerers {
+		registerer.SetClient(client)
+	}
+
+	configReloadSuccess.Set(1)
+	configReloadSeconds.SetToCurrentTime()
+	level.Info(r.logger).Log("msg", "configuration reloaded", "file", *configFile)
+	return nil
+}
+
+// watchSignals reloads the configuration on every SIGHUP.
+func (r *reloader) watchSignals() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		r.reload()
+	}
+}
+
+// handler reloads the configuration on POST requests.
+func (r *reloader) handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.reload(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// newClient builds a client from the configuration file or, if none is given, from the flags.
+func newClient(logger log.Logger) (*mongodbatlas.AtlasClient, error) {
+	cfg := config.FromFlags(*atlasPublicKey, *atlasPrivateKey, *atlasOrgID, *atlasProjectIDs, *atlasClusters)
+
+	if *configFile != "" {
+		var err error
+		cfg, err = config.Load(*configFile, *atlasPublicKey, *atlasPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if *collectDatabases {
+		cfg.Databases.Enabled = true
+	}
+	if *collectAdvisor {
+		cfg.PerformanceAdvisor.Enabled = true
+	}
+
+	return mongodbatlas.NewClient(logger, cfg)
+}